@@ -0,0 +1,1051 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+)
+
+const actionTypeParallel = "parallel"
+
+// actionArgs are the parsed command-line arguments of :pa.
+type actionArgs struct {
+	Query       string            // Action name, key or fuzzy query
+	Inputs      map[string]string // name=value inputs
+	Extra       []string          // Arguments after "--", forwarded to the action
+	Multi       bool              // -m: pick several actions and run them in parallel
+	MaxParallel int               // --max-parallel N, 0 means unlimited
+	FailFast    bool              // --fail-fast: cancel siblings on the first failure
+	Yes         bool              // --yes: skip confirmation of guarded actions
+	Last        bool              // !! or --last: rerun the last action in this workspace
+	History     bool              // --history: pick an earlier run to repeat
+	Replay      []string          // Action names taken from a history entry
+	Watch       bool              // --watch: rerun the action when files change
+	All         bool              // --all: also offer actions hidden by their "when" conditions
+	Background  bool              // --bg: run the action as a detached background job
+	AllPackages bool              // --all-packages: run the action in every package it applies to
+}
+
+// actionRun carries the state of a single :pa invocation.
+type actionRun struct {
+	ws       *workspace
+	actions  []RepoAction
+	sections []actionSection
+	hidden   map[*RepoAction]string // Why actions whose "when" conditions fail are hidden
+	args     actionArgs
+	target   *RepoAction                       // The action that was asked for; receives Extra args
+	inputs   map[*RepoAction]map[string]string // Resolved inputs per step
+	log      *runLog                           // Log file the output is copied to, if enabled
+	jobs     map[string]logMeta                // Background jobs running in this workspace, by action name
+	packages map[*RepoAction]string            // Package directory per step, for actions with packages
+	origin   map[*RepoAction]*RepoAction       // The action each --all-packages copy was made from
+	notify   *NotifyConfig                     // Global notify settings
+	hooks    []*Hooks                          // Global hooks, then those of matching git_repos entries
+}
+
+func cmdProjectActions(cfg *Config, args []string) error {
+	parsed, err := parseActionArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ws, err := currentWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+
+	actions, sections := ws.actions()
+	if len(actions) == 0 {
+		return errors.New("no actions found for this repository in colonsh.json")
+	}
+	actions, sections = prioritizePackage(ws, actions, sections)
+
+	if parsed.Last || parsed.History {
+		var entry historyEntry
+		if parsed.Last {
+			entry, err = lastRun(ws)
+		} else {
+			var ok bool
+			entry, ok, err = selectRun(ws)
+			if err == nil && !ok {
+				fmt.Println("No run selected.")
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		parsed = entry.replay(parsed)
+	}
+
+	run := &actionRun{ws: ws, actions: actions, sections: sections, hidden: hiddenActions(ws, actions), args: parsed, jobs: runningJobs(ws), notify: cfg.Notify, hooks: workspaceHooks(cfg, ws)}
+	ctx := context.Background()
+
+	if parsed.Multi {
+		var selected []*RepoAction
+		if len(parsed.Replay) > 0 {
+			selected, err = actionsByName(actions, parsed.Replay)
+		} else {
+			selected, err = run.selectActions()
+		}
+		if err != nil {
+			return err
+		}
+		if err := run.available(selected...); err != nil {
+			return err
+		}
+		if len(selected) == 0 {
+			fmt.Println("No action selected.")
+			return nil
+		}
+		for _, a := range selected {
+			if _, _, err := run.plan(a); err != nil {
+				return err
+			}
+		}
+		if err := run.resolvePackages(selected); err != nil {
+			return err
+		}
+		if err := run.collectInputs(selected); err != nil {
+			return err
+		}
+		if err := run.confirmSteps(selected); err != nil {
+			return err
+		}
+		if err := run.runHook(hookPreAction, selected); err != nil {
+			return err
+		}
+		run.startLog(cfg.Logs, selected)
+		start := time.Now()
+		err = run.runParallel(ctx, selected, parsed.FailFast, parsed.MaxParallel)
+		run.finish(selected, start, err)
+		return err
+	}
+
+	var action *RepoAction
+	if len(parsed.Replay) > 0 {
+		found, err := actionsByName(actions, parsed.Replay)
+		if err != nil {
+			return err
+		}
+		action = found[0]
+	} else if group := findGroup(actions, parsed.Query); parsed.Query != "" && group == nil {
+		skip := run.hidden
+		if parsed.All {
+			skip = nil
+		}
+		action, err = matchAction(actions, parsed.Query, skip)
+		if err != nil {
+			return err
+		}
+	} else {
+		// No query, or the path of a group: pick from the menu
+		action, err = run.selectAction(group)
+		if err != nil {
+			return err
+		}
+		if action == nil {
+			fmt.Println("No action selected.")
+			return nil
+		}
+	}
+	if err := run.available(action); err != nil {
+		return err
+	}
+	run.target = action
+	if parsed.AllPackages && (len(action.Packages) == 0 || action.Type == actionTypeParallel) {
+		return fmt.Errorf("action %q has no packages; --all-packages needs an action with \"packages\"", action.Name)
+	}
+	// A dry run only prints commands, which a background job has nowhere to show
+	background := (parsed.Background || action.Background) && !parsed.Watch && !dryRun
+	if background {
+		if err := run.checkNotRunning(); err != nil {
+			return err
+		}
+	}
+
+	plan, steps, err := run.plan(action)
+	if err != nil {
+		return err
+	}
+	if err := run.resolvePackages(steps); err != nil {
+		return err
+	}
+	if err := run.collectInputs(steps); err != nil {
+		return err
+	}
+	if err := run.confirmSteps(steps); err != nil {
+		return err
+	}
+
+	if parsed.Watch {
+		return run.watch(ctx, cfg.Logs, plan, steps)
+	}
+	if err := run.runHook(hookPreAction, steps); err != nil {
+		return err
+	}
+	if background {
+		return run.startJob(steps)
+	}
+
+	run.startLog(cfg.Logs, steps)
+	start := time.Now()
+	err = run.runPlan(ctx, plan)
+	run.finish(steps, start, err)
+	return err
+}
+
+// parseActionArgs separates the action query from flags, "name=value" inputs and
+// the arguments forwarded after "--".
+func parseActionArgs(args []string) (actionArgs, error) {
+	parsed := actionArgs{Inputs: make(map[string]string)}
+	var words []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			parsed.Extra = args[i+1:]
+			i = len(args)
+		case a == "-m" || a == "--multi":
+			parsed.Multi = true
+		case a == "--fail-fast":
+			parsed.FailFast = true
+		case a == "--yes" || a == "-y":
+			parsed.Yes = true
+		case a == "!!" || a == "--last":
+			parsed.Last = true
+		case a == "--history":
+			parsed.History = true
+		case a == "--watch" || a == "-w":
+			parsed.Watch = true
+		case a == "--all":
+			parsed.All = true
+		case a == "--bg" || a == "--background":
+			parsed.Background = true
+		case a == "--all-packages":
+			parsed.AllPackages = true
+		case a == "--max-parallel" || strings.HasPrefix(a, "--max-parallel="):
+			v, ok := strings.CutPrefix(a, "--max-parallel=")
+			if !ok {
+				if i+1 >= len(args) {
+					return parsed, errors.New("--max-parallel requires a value")
+				}
+				i++
+				v = args[i]
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return parsed, fmt.Errorf("invalid --max-parallel value %q", v)
+			}
+			parsed.MaxParallel = n
+		default:
+			if name, value, ok := parseInputArg(a); ok {
+				parsed.Inputs[name] = value
+				continue
+			}
+			words = append(words, a)
+		}
+	}
+	parsed.Query = strings.Join(words, " ")
+	if (parsed.Last || parsed.History) && (parsed.Query != "" || parsed.Multi) {
+		return parsed, errors.New("!! and --history rerun a recorded run and take no action name or -m")
+	}
+	if parsed.Watch && parsed.Multi {
+		return parsed, errors.New("--watch can't be combined with -m; use a parallel action instead")
+	}
+	if parsed.AllPackages && parsed.Multi {
+		return parsed, errors.New("--all-packages can't be combined with -m")
+	}
+	if parsed.Background && (parsed.Multi || parsed.Watch) {
+		return parsed, errors.New("--bg can't be combined with -m or --watch; use a parallel action instead")
+	}
+	return parsed, nil
+}
+
+// collectInputs resolves inputs for every step up front, so runs aren't interrupted
+// by prompts. Command-line inputs go to each step that declares them.
+func (r *actionRun) collectInputs(steps []*RepoAction) error {
+	declares := func(step *RepoAction, name string) bool {
+		return slices.ContainsFunc(step.Inputs, func(in ActionInput) bool { return in.Name == name })
+	}
+
+	// A single step gets every input so resolveInputs can report unknown names in detail
+	if len(steps) > 1 {
+		for name := range r.args.Inputs {
+			if !slices.ContainsFunc(steps, func(step *RepoAction) bool { return declares(step, name) }) {
+				return fmt.Errorf("none of the selected actions has an input %q", name)
+			}
+		}
+	}
+
+	r.inputs = make(map[*RepoAction]map[string]string, len(steps))
+	for _, step := range steps {
+		own := make(map[string]string)
+		for name, v := range r.args.Inputs {
+			if len(steps) == 1 || declares(step, name) {
+				own[name] = v
+			}
+		}
+		values, err := resolveInputs(step, own)
+		if err != nil {
+			return err
+		}
+		r.inputs[step] = values
+	}
+	return nil
+}
+
+// name describes the run: the action that was asked for, or the actions picked with -m.
+func (r *actionRun) name(steps []*RepoAction) string {
+	if r.target != nil {
+		return r.target.Name
+	}
+	var names []string
+	for _, step := range steps {
+		names = append(names, step.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// startLog starts copying output to a log file when logging is enabled for steps.
+// Failing to create the log is reported but doesn't stop the run.
+func (r *actionRun) startLog(lc *LogConfig, steps []*RepoAction) {
+	if dryRun || !logEnabled(lc, steps) {
+		return
+	}
+	l, err := startRunLog(lc, r.ws, r.name(steps), false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to create log: %v\n", err)
+		return
+	}
+	r.log = l
+	fmt.Printf("Logging to %s (follow with: colonsh logs %s --follow)\n", l.path(), l.meta.ID)
+}
+
+// finish runs the post_action and on_failure hooks, closes the log, records the
+// run in the history and sends a notification if the run was long enough to want one.
+func (r *actionRun) finish(steps []*RepoAction, start time.Time, runErr error) {
+	r.finishHooks(steps, time.Since(start), runErr)
+	if dryRun {
+		// Nothing ran, so there is nothing to log, record or notify about
+		return
+	}
+	if r.log != nil {
+		r.log.finish(runErr)
+	}
+	r.recordRun(steps, start, runErr)
+	r.sendNotification(steps, time.Since(start), runErr)
+}
+
+// tee returns w, also copying to the run's log when there is one.
+func (r *actionRun) tee(w io.Writer) io.Writer {
+	if r.log == nil {
+		return w
+	}
+	return io.MultiWriter(w, r.log)
+}
+
+// stepResult records the outcome of one step of an action pipeline.
+type stepResult struct {
+	Name      string
+	Duration  time.Duration
+	ExitCode  int
+	Ran       bool
+	Cancelled bool
+}
+
+// planAction resolves depends_on into an ordered list of steps ending with target.
+// Each action appears once; unknown dependencies and cycles are errors.
+func planAction(actions []RepoAction, target *RepoAction) ([]*RepoAction, error) {
+	byName := make(map[string]*RepoAction, len(actions))
+	for i := range actions {
+		byName[strings.ToLower(actions[i].Name)] = &actions[i]
+	}
+
+	var plan []*RepoAction
+	done := make(map[*RepoAction]bool)
+	var path []string
+
+	var visit func(a *RepoAction) error
+	visit = func(a *RepoAction) error {
+		if done[a] {
+			return nil
+		}
+		for i, name := range path {
+			if name == a.Name {
+				cycle := append(append([]string{}, path[i:]...), a.Name)
+				return &actionError{a, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))}
+			}
+		}
+		path = append(path, a.Name)
+		for _, dep := range a.DependsOn {
+			d, ok := byName[strings.ToLower(dep)]
+			if !ok {
+				return &actionError{a, fmt.Errorf("action %q depends on unknown action %q", a.Name, dep)}
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		done[a] = true
+		plan = append(plan, a)
+		return nil
+	}
+
+	if err := visit(target); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// actionError is a mistake in the definition of one action, such as a dependency
+// that doesn't exist.
+type actionError struct {
+	action *RepoAction
+	err    error
+}
+
+func (e *actionError) Error() string { return e.err.Error() }
+func (e *actionError) Unwrap() error { return e.err }
+
+// plan plans target like planSteps. Mistakes in the actions it needs are reported
+// with the section of the menu they come from.
+func (r *actionRun) plan(target *RepoAction) (plan, steps []*RepoAction, err error) {
+	plan, steps, err = planSteps(r.actions, target)
+	var ae *actionError
+	if errors.As(err, &ae) {
+		if title := r.sectionOf(ae.action); title != "" {
+			err = fmt.Errorf("%w (in %s)", err, title)
+		}
+	}
+	return plan, steps, err
+}
+
+// sectionOf returns the title of the menu section a comes from, if there is one.
+func (r *actionRun) sectionOf(a *RepoAction) string {
+	title := ""
+	for i := range r.actions {
+		if &r.actions[i] != a {
+			continue
+		}
+		for _, s := range r.sections {
+			if i >= s.Start {
+				title = s.Title
+			}
+		}
+	}
+	return title
+}
+
+// planSteps plans target and lists the command steps the plan runs, with parallel
+// actions replaced by their members.
+func planSteps(actions []RepoAction, target *RepoAction) (plan, steps []*RepoAction, err error) {
+	plan, err = planAction(actions, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, step := range plan {
+		if step.Type == actionTypeParallel {
+			members, err := parallelMembers(actions, step)
+			if err != nil {
+				return nil, nil, err
+			}
+			steps = append(steps, members...)
+		} else {
+			steps = append(steps, step)
+		}
+	}
+	return plan, steps, nil
+}
+
+// runPlan runs each step in order, stopping at the first failure. Pipelines of
+// more than one step end with a summary.
+func (r *actionRun) runPlan(ctx context.Context, plan []*RepoAction) error {
+	if len(plan) == 1 {
+		return r.runStep(ctx, plan[0])
+	}
+
+	results := make([]stepResult, len(plan))
+	var runErr error
+	for i, step := range plan {
+		results[i].Name = step.Name
+		if runErr != nil {
+			continue
+		}
+
+		start := time.Now()
+		runErr = r.runStep(ctx, step)
+		results[i].Duration = time.Since(start)
+		results[i].ExitCode = exitCode(runErr)
+		results[i].Ran = true
+	}
+
+	printPlanSummary(results)
+	if runErr != nil {
+		return fmt.Errorf("step %q failed: %w", results[failedStep(results)].Name, runErr)
+	}
+	return nil
+}
+
+// runStep runs a single command action, or fans out a parallel group.
+func (r *actionRun) runStep(ctx context.Context, action *RepoAction) error {
+	if action.Type == actionTypeParallel {
+		members, err := parallelMembers(r.actions, action)
+		if err != nil {
+			return err
+		}
+		limit := action.MaxParallel
+		if r.args.MaxParallel > 0 {
+			limit = r.args.MaxParallel
+		}
+		return r.runParallel(ctx, members, action.FailFast || r.args.FailFast, limit)
+	}
+	if action == r.target && r.args.AllPackages {
+		return r.runPackages(ctx, action)
+	}
+
+	sc, display, err := r.command(action)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Executing action %q in %s: %s\n", action.Name, sc.Dir, display)
+	if r.log != nil {
+		fmt.Fprintf(r.log, "$ %s\n", display)
+		sc.Stdout, sc.Stderr = r.tee(os.Stdout), r.tee(os.Stderr)
+	}
+	return runWithPolicy(ctx, action, sc, r.tee(os.Stdout))
+}
+
+// command builds the shell command for an action, substituting inputs and appending
+// forwarded arguments. The second value is safe to display (passwords masked).
+func (r *actionRun) command(action *RepoAction) (shellCommand, string, error) {
+	// Copies made by --all-packages share the inputs of the action they were made from
+	origin := action
+	if o := r.origin[action]; o != nil {
+		origin = o
+	}
+
+	// Actions with packages run in theirs, with dir relative to it
+	runDir := r.ws.Root
+	pkg := r.packages[action]
+	if pkg != "" {
+		runDir = filepath.Join(runDir, filepath.FromSlash(pkg))
+	}
+	if action.Dir != "" && action.Dir != "." {
+		runDir = filepath.Join(runDir, action.Dir)
+	}
+
+	// Forwarded arguments only apply to the action that was asked for
+	var extra []string
+	if origin == r.target {
+		extra = r.args.Extra
+	}
+
+	// Watch mode keeps the terminal so Ctrl-C stops the watch, not just the run
+	sc := shellCommand{Dir: runDir, NoForeground: r.args.Watch}
+	if err := applyExecOptions(&sc, action.ExecOptions, r.ws.Root); err != nil {
+		return sc, "", fmt.Errorf("action %q: %w", action.Name, err)
+	}
+
+	inputs := r.inputs[origin]
+	if sc.Shell == "none" {
+		// No shell to interpret quoting: substitute raw values into each argument
+		argv := action.Argv
+		if len(argv) == 0 {
+			argv = strings.Fields(action.Cmd)
+		}
+		var shown []string
+		for _, a := range argv {
+			a = strings.ReplaceAll(a, packagePlaceholder, pkg)
+			sc.Argv = append(sc.Argv, substituteInputsRaw(a, action.Inputs, inputs, false))
+			shown = append(shown, shellQuoteArg(substituteInputsRaw(a, action.Inputs, inputs, true)))
+		}
+		sc.Argv = append(sc.Argv, extra...)
+		for _, a := range extra {
+			shown = append(shown, shellQuoteArg(a))
+		}
+		return sc, strings.Join(shown, " "), nil
+	}
+
+	var forwarded string
+	for _, a := range extra {
+		forwarded += " " + shellQuoteArg(a)
+	}
+	cmd := strings.ReplaceAll(action.Cmd, packagePlaceholder, shellQuoteArg(pkg))
+	sc.Cmd = substituteInputs(cmd, action.Inputs, inputs, false) + forwarded
+	display := substituteInputs(cmd, action.Inputs, inputs, true) + forwarded
+	return sc, display, nil
+}
+
+// printPlanSummary prints one line per step with its status, duration and exit code.
+// Dry runs print nothing, as no step ran.
+func printPlanSummary(results []stepResult) {
+	if dryRun {
+		return
+	}
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Name))
+	}
+
+	fmt.Println("\nSummary:")
+	for _, r := range results {
+		switch {
+		case !r.Ran:
+			fmt.Printf("  - %-*s  %8s  skipped\n", width, r.Name, "")
+		case r.Cancelled:
+			fmt.Printf("  - %-*s  %8s  cancelled\n", width, r.Name, r.Duration.Round(time.Millisecond))
+		case r.ExitCode == 0:
+			fmt.Printf("  ✓ %-*s  %8s  exit 0\n", width, r.Name, r.Duration.Round(time.Millisecond))
+		default:
+			fmt.Printf("  ✗ %-*s  %8s  exit %d\n", width, r.Name, r.Duration.Round(time.Millisecond), r.ExitCode)
+		}
+	}
+}
+
+// failedStep returns the index of the first step that ran and failed.
+func failedStep(results []stepResult) int {
+	for i, r := range results {
+		if r.Ran && !r.Cancelled && r.ExitCode != 0 {
+			return i
+		}
+	}
+	return len(results) - 1
+}
+
+// timeoutExitCode is the exit code for timed-out actions, matching timeout(1).
+const timeoutExitCode = 124
+
+// exitError carries the exit code colonsh should exit with for an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode extracts a process exit code from an error. Processes killed by a
+// signal report 128+signal, like shells do.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var xe *exitError
+	if errors.As(err, &xe) {
+		return xe.code
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		if code := ee.ExitCode(); code >= 0 {
+			return code
+		}
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() > 0 {
+			return 128 + int(ws.Signal())
+		}
+	}
+	return 1
+}
+
+// wasInterrupted reports whether a command stopped because the user asked it to,
+// in which case it must not be retried.
+func wasInterrupted(err error) bool {
+	if errors.Is(err, errInterrupted) {
+		return true
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return ws.Signal() == syscall.SIGINT || ws.Signal() == syscall.SIGTERM
+		}
+	}
+	return false
+}
+
+// runWithPolicy runs sc under the action's timeout and retry settings. Retry
+// notices are written to out.
+func runWithPolicy(ctx context.Context, action *RepoAction, sc shellCommand, out io.Writer) error {
+	// Both were validated when the config was loaded
+	timeout, _ := parseOptionalDuration(action.Timeout)
+	delay, _ := parseOptionalDuration(action.RetryDelay)
+	attempts := action.Retries + 1
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(out, "Retrying %q (attempt %d of %d) in %s\n", action.Name, attempt, attempts, delay)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return err
+			}
+		}
+
+		err = runWithTimeout(ctx, sc, timeout)
+		if err == nil || wasInterrupted(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	if attempts > 1 {
+		return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+// runWithTimeout runs sc, killing its process group if timeout (when non-zero) expires.
+func runWithTimeout(ctx context.Context, sc shellCommand, timeout time.Duration) error {
+	if timeout == 0 {
+		return sc.Run(ctx)
+	}
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := sc.Run(tctx)
+	if err != nil && errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		return &exitError{code: timeoutExitCode, err: fmt.Errorf("timed out after %s", timeout)}
+	}
+	return err
+}
+
+// sectionHeader is the action index of section header options in the action pickers.
+const sectionHeader = -2
+
+// errSectionHeader is shown when a section header is submitted in an action picker.
+var errSectionHeader = errors.New("section headers can't be selected")
+
+// menuItem is the value of an action picker option: an action, a section header,
+// or a group to open.
+type menuItem struct {
+	action int    // Index into actions, sectionHeader, or -1 for groups
+	group  string // Name of the group to open
+}
+
+// actionGroup splits the group path of a, e.g. "DB/Migrations", into its segments.
+func actionGroup(a *RepoAction) []string {
+	if a.Group == "" {
+		return nil
+	}
+	return strings.Split(a.Group, "/")
+}
+
+// actionPath is how an action is addressed on the command line: its name, after
+// its group path if it has one, e.g. "deploy/staging".
+func actionPath(a *RepoAction) string {
+	if a.Group == "" {
+		return a.Name
+	}
+	return a.Group + "/" + a.Name
+}
+
+// inGroup reports whether group starts with prefix, ignoring case.
+func inGroup(group, prefix []string) bool {
+	return len(group) >= len(prefix) && slices.EqualFunc(group[:len(prefix)], prefix, strings.EqualFold)
+}
+
+// actionOptions builds picker options for the actions accepted by include within the
+// group prefix, under a header per section when they come from more than one source.
+// When nested, subgroups are collapsed into one option each, placed where their first
+// action is; otherwise grouped actions are labelled with their group. Hidden actions
+// are left out, or shown with the reason with --all.
+func (r *actionRun) actionOptions(include func(*RepoAction) bool, prefix []string, nested bool) ([]huh.Option[menuItem], error) {
+	perSection := make([][]huh.Option[menuItem], len(r.sections))
+	offered, sources := 0, 0
+	groups := make(map[string]bool)
+	for s, sec := range r.sections {
+		end := len(r.actions)
+		if s+1 < len(r.sections) {
+			end = r.sections[s+1].Start
+		}
+		for i := sec.Start; i < end; i++ {
+			a := &r.actions[i]
+			reason := r.hidden[a]
+			group := actionGroup(a)
+			if !include(a) || (reason != "" && !r.args.All) || !inGroup(group, prefix) {
+				continue
+			}
+
+			if nested && len(group) > len(prefix) {
+				// Groups from different sources merge when their names differ only in case
+				name := group[len(prefix)]
+				if groups[strings.ToLower(name)] {
+					continue
+				}
+				groups[strings.ToLower(name)] = true
+				perSection[s] = append(perSection[s], huh.NewOption(name+" ▸", menuItem{action: -1, group: name}))
+			} else {
+				label := actionLabel(a)
+				if len(group) > len(prefix) {
+					label = strings.Join(group[len(prefix):], " › ") + " › " + label
+				}
+				if _, ok := r.jobs[a.Name]; ok {
+					label += " [running]"
+				}
+				if reason != "" {
+					label += " (hidden: " + reason + ")"
+				}
+				perSection[s] = append(perSection[s], huh.NewOption(label, menuItem{action: i}))
+			}
+			offered++
+		}
+		if len(perSection[s]) > 0 {
+			sources++
+		}
+	}
+	if offered == 0 {
+		return nil, fmt.Errorf("no actions available here; %d hidden by their conditions (see :pa --all)", len(r.hidden))
+	}
+
+	opts := []huh.Option[menuItem]{}
+	for s, sec := range r.sections {
+		if sources > 1 && len(perSection[s]) > 0 {
+			opts = append(opts, huh.NewOption("── "+sec.Title+" ──", menuItem{action: sectionHeader}))
+		}
+		opts = append(opts, perSection[s]...)
+	}
+	return opts, nil
+}
+
+// available returns an error explaining why if any of actions is hidden, unless --all was given.
+func (r *actionRun) available(actions ...*RepoAction) error {
+	if r.args.All {
+		return nil
+	}
+	for _, a := range actions {
+		if reason := r.hidden[a]; reason != "" {
+			return fmt.Errorf("action %q is hidden here (%s); use --all to run it anyway", a.Name, reason)
+		}
+	}
+	return nil
+}
+
+// selectAction shows the interactive action picker, starting in the group prefix.
+// Choosing a group opens it and Esc goes back up; Esc at the top level cancels.
+// It returns nil if nothing was chosen.
+func (r *actionRun) selectAction(prefix []string) (*RepoAction, error) {
+	var back string // Group to put the cursor on after going back up
+	for {
+		opts, err := r.actionOptions(func(*RepoAction) bool { return true }, prefix, true)
+		if err != nil {
+			return nil, err
+		}
+
+		title, desc := "Select an action", ""
+		if len(prefix) > 0 {
+			title, desc = "Actions › "+strings.Join(prefix, " › "), "esc to go back"
+		}
+		// Value goes first: Options moves the cursor to the option holding the current value
+		selected := menuItem{action: -1, group: back}
+		sel := huh.NewSelect[menuItem]().
+			Title(title).
+			Description(desc).
+			Value(&selected).
+			Options(opts...).
+			Validate(func(m menuItem) error {
+				if m.action == sectionHeader {
+					return errSectionHeader
+				}
+				return nil
+			})
+		goBack, err := runMenu(sel)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case goBack && len(prefix) == 0:
+			return nil, nil
+		case goBack:
+			back, prefix = prefix[len(prefix)-1], prefix[:len(prefix)-1]
+		case selected.group != "":
+			back, prefix = "", append(slices.Clip(prefix), selected.group)
+		case selected.action < 0:
+			return nil, nil
+		default:
+			return &r.actions[selected.action], nil
+		}
+	}
+}
+
+// runMenu runs sel and reports whether Esc was pressed to go back. While sel is
+// taking filter input, Esc is left to the field.
+func runMenu(sel *huh.Select[menuItem]) (bool, error) {
+	back := false
+	err := huh.NewForm(huh.NewGroup(sel)).
+		WithShowHelp(false).
+		WithProgramOptions(tea.WithFilter(func(_ tea.Model, msg tea.Msg) tea.Msg {
+			if k, ok := msg.(tea.KeyMsg); ok && k.Type == tea.KeyEsc && !sel.GetFiltering() {
+				back = true
+				return tea.QuitMsg{}
+			}
+			return msg
+		})).
+		Run()
+	return back, err
+}
+
+// selectActions shows a multi-select picker of command actions for parallel runs.
+func (r *actionRun) selectActions() ([]*RepoAction, error) {
+	opts, err := r.actionOptions(func(a *RepoAction) bool { return a.Type != actionTypeParallel }, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected []menuItem
+	if err := huh.NewMultiSelect[menuItem]().
+		Title("Select actions to run in parallel").
+		Options(opts...).
+		Value(&selected).
+		Validate(func(sel []menuItem) error {
+			if slices.Contains(sel, menuItem{action: sectionHeader}) {
+				return errSectionHeader
+			}
+			return nil
+		}).
+		Run(); err != nil {
+		return nil, err
+	}
+
+	var out []*RepoAction
+	for _, m := range selected {
+		out = append(out, &r.actions[m.action])
+	}
+	return out, nil
+}
+
+// findGroup returns the group path that query names, e.g. "deploy" or "db/migrations/",
+// or nil if it names none. Actions whose key or name equals query take precedence.
+func findGroup(actions []RepoAction, query string) []string {
+	q := strings.TrimSuffix(query, "/")
+	if q == "" || slices.ContainsFunc(actions, func(a RepoAction) bool {
+		return strings.EqualFold(a.Key, query) || strings.EqualFold(a.Name, query)
+	}) {
+		return nil
+	}
+	for i := range actions {
+		group := actionGroup(&actions[i])
+		for n := 1; n <= len(group); n++ {
+			if strings.EqualFold(strings.Join(group[:n], "/"), q) {
+				return group[:n]
+			}
+		}
+	}
+	return nil
+}
+
+// matchAction finds the action for a query typed on the command line. It tries, in order:
+// an exact key, an exact name, an exact group path (all ignoring case), then a unique
+// prefix, substring or fuzzy match. Queries with a slash are matched against group
+// paths, so "dep/stag" finds "staging" in the "deploy" group. Actions in skip only
+// match exactly.
+func matchAction(actions []RepoAction, query string, skip map[*RepoAction]string) (*RepoAction, error) {
+	q := strings.ToLower(query)
+
+	for i := range actions {
+		if actions[i].Key != "" && strings.EqualFold(actions[i].Key, query) {
+			return &actions[i], nil
+		}
+	}
+	for i := range actions {
+		if strings.EqualFold(actions[i].Name, query) {
+			return &actions[i], nil
+		}
+	}
+	for i := range actions {
+		if strings.EqualFold(actionPath(&actions[i]), query) {
+			return &actions[i], nil
+		}
+	}
+
+	target := func(a *RepoAction) string {
+		if strings.Contains(q, "/") {
+			return strings.ToLower(actionPath(a))
+		}
+		return strings.ToLower(a.Name)
+	}
+
+	matchers := []func(name string) bool{
+		func(name string) bool { return strings.HasPrefix(name, q) },
+		func(name string) bool { return strings.Contains(name, q) },
+		func(name string) bool { return fuzzyMatch(name, q) },
+	}
+	for _, match := range matchers {
+		var found []*RepoAction
+		for i := range actions {
+			if skip[&actions[i]] == "" && match(target(&actions[i])) {
+				found = append(found, &actions[i])
+			}
+		}
+		switch len(found) {
+		case 0:
+			continue
+		case 1:
+			return found[0], nil
+		default:
+			var names []string
+			for _, a := range found {
+				names = append(names, actionPath(a))
+			}
+			return nil, fmt.Errorf("action %q is ambiguous, matches: %s", query, strings.Join(names, ", "))
+		}
+	}
+
+	return nil, fmt.Errorf("no action matches %q. Valid actions:\n%s", query, describeActions(actions, skip))
+}
+
+// actionsByName looks up actions by their exact names, as recorded in the run history.
+func actionsByName(actions []RepoAction, names []string) ([]*RepoAction, error) {
+	var out []*RepoAction
+	for _, name := range names {
+		i := slices.IndexFunc(actions, func(a RepoAction) bool { return a.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("action %q no longer exists. Valid actions:\n%s", name, describeActions(actions, nil))
+		}
+		out = append(out, &actions[i])
+	}
+	return out, nil
+}
+
+// fuzzyMatch reports whether the characters of pattern appear in s in order.
+func fuzzyMatch(s, pattern string) bool {
+	p := []rune(strings.ReplaceAll(pattern, " ", ""))
+	i := 0
+	for _, r := range s {
+		if i == len(p) {
+			break
+		}
+		if r == p[i] {
+			i++
+		}
+	}
+	return i == len(p)
+}
+
+// describeActions lists action paths (and keys) for error messages, leaving out
+// hidden actions.
+func describeActions(actions []RepoAction, hidden map[*RepoAction]string) string {
+	var b strings.Builder
+	for i := range actions {
+		a := &actions[i]
+		switch {
+		case hidden[a] != "":
+		case a.Key != "":
+			fmt.Fprintf(&b, "  %s (%s)\n", actionPath(a), a.Key)
+		default:
+			fmt.Fprintf(&b, "  %s\n", actionPath(a))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
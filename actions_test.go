@@ -0,0 +1,198 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchAction(t *testing.T) {
+	actions := []RepoAction{
+		{Name: "Test", Key: "t"},
+		{Name: "Test All"},
+		{Name: "Build"},
+		{Name: "Build Docker"},
+		{Name: "staging", Group: "Deploy"},
+		{Name: "prod", Group: "Deploy"},
+		{Name: "up", Group: "DB/Migrations"},
+		{Name: "Lint"},
+	}
+	hidden := map[*RepoAction]string{&actions[7]: "branch is not main"}
+
+	tests := []struct {
+		query   string
+		skip    map[*RepoAction]string
+		want    string // Name of the matched action
+		wantErr string // Substring of the error
+	}{
+		{query: "t", want: "Test"},                  // key
+		{query: "T", want: "Test"},                  // key, any case
+		{query: "test", want: "Test"},               // exact name beats the "Test All" prefix
+		{query: "build", want: "Build"},             // exact name
+		{query: "Deploy/prod", want: "prod"},        // exact path
+		{query: "test a", want: "Test All"},         // prefix
+		{query: "dock", want: "Build Docker"},       // substring
+		{query: "bdr", want: "Build Docker"},        // fuzzy
+		{query: "db/mig/up", want: "up"},            // fuzzy across path segments
+		{query: "deploy/st", want: "staging"},       // path prefix
+		{query: "migrations/u", want: "up"},         // path substring
+		{query: "bui", wantErr: "ambiguous"},        // prefix of two names
+		{query: "ing", want: "staging"},             // substring of one name only
+		{query: "deploy/", wantErr: "ambiguous"},    // every action in the group
+		{query: "lint", skip: hidden, want: "Lint"}, // exact names ignore skip
+		{query: "lin", skip: hidden, wantErr: "no action"},
+		{query: "lin", want: "Lint"},
+		{query: "zzz", wantErr: "Valid actions"},
+	}
+	for _, tt := range tests {
+		got, err := matchAction(actions, tt.query, tt.skip)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("matchAction(%q) = %v, %v; want error containing %q", tt.query, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("matchAction(%q): %v", tt.query, err)
+			continue
+		}
+		if got.Name != tt.want {
+			t.Errorf("matchAction(%q) = %q, want %q", tt.query, got.Name, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		s, pattern string
+		want       bool
+	}{
+		{"build docker", "bdr", true},
+		{"build docker", "b d", true},
+		{"build docker", "rb", false},
+		{"test", "", true},
+		{"", "x", false},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.s, tt.pattern); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.s, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestPlanAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []RepoAction
+		want    string // Plan as step names joined by spaces
+		wantErr string
+	}{
+		{
+			name:    "no dependencies",
+			actions: []RepoAction{{Name: "build"}},
+			want:    "build",
+		},
+		{
+			name:    "chain in dependency order",
+			actions: []RepoAction{{Name: "deploy", DependsOn: []string{"test"}}, {Name: "test", DependsOn: []string{"build"}}, {Name: "build"}},
+			want:    "build test deploy",
+		},
+		{
+			name: "shared dependency runs once",
+			actions: []RepoAction{
+				{Name: "release", DependsOn: []string{"test", "lint"}},
+				{Name: "test", DependsOn: []string{"build"}},
+				{Name: "lint", DependsOn: []string{"build"}},
+				{Name: "build"},
+			},
+			want: "build test lint release",
+		},
+		{
+			name:    "names match in any case",
+			actions: []RepoAction{{Name: "deploy", DependsOn: []string{"BUILD"}}, {Name: "Build"}},
+			want:    "Build deploy",
+		},
+		{
+			name:    "self-dependency",
+			actions: []RepoAction{{Name: "loop", DependsOn: []string{"loop"}}},
+			wantErr: "dependency cycle: loop -> loop",
+		},
+		{
+			name:    "cycle",
+			actions: []RepoAction{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"c"}}, {Name: "c", DependsOn: []string{"a"}}},
+			wantErr: "dependency cycle: a -> b -> c -> a",
+		},
+		{
+			name:    "unknown dependency",
+			actions: []RepoAction{{Name: "deploy", DependsOn: []string{"biuld"}}},
+			wantErr: `depends on unknown action "biuld"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planAction(tt.actions, &tt.actions[0])
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("planAction = %v; want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, a := range plan {
+				names = append(names, a.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("plan = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	// Names outside the list may be global or generated actions, checked by :pa
+	outside := []RepoAction{
+		{Name: "deploy", DependsOn: []string{"make build"}},
+		{Name: "checks", Type: actionTypeParallel, Steps: []string{"lint", "make build"}},
+		{Name: "lint"},
+	}
+	if err := checkDependencies(outside); err != nil {
+		t.Errorf("checkDependencies(outside) = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		actions []RepoAction
+		wantErr string
+	}{
+		{"no steps", []RepoAction{{Name: "all", Type: actionTypeParallel}}, "has no steps"},
+		{"nested parallel", []RepoAction{{Name: "all", Type: actionTypeParallel, Steps: []string{"inner"}}, {Name: "inner", Type: actionTypeParallel, Steps: []string{"x"}}, {Name: "x"}}, "cannot contain parallel"},
+		{"cycle past the first action", []RepoAction{{Name: "ok"}, {Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a", "global"}}}, "dependency cycle"},
+	}
+	for _, tt := range tests {
+		if err := checkDependencies(tt.actions); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: checkDependencies = %v, want error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPlanNamesSection(t *testing.T) {
+	run := &actionRun{
+		actions: []RepoAction{
+			{Name: "deploy", DependsOn: []string{"lint"}},
+			{Name: "lint", DependsOn: []string{"biuld"}},
+			{Name: "other", DependsOn: []string{"missing"}},
+		},
+		sections: []actionSection{{Title: "Repository"}, {Title: "Global", Start: 1}},
+	}
+	_, _, err := run.plan(&run.actions[0])
+	if err == nil || !strings.Contains(err.Error(), `"biuld"`) || !strings.HasSuffix(err.Error(), "(in Global)") {
+		t.Errorf("plan(deploy) = %v, want the unknown dependency of lint in Global", err)
+	}
+	// Mistakes in actions the plan doesn't need are not reported
+	run.actions[1].DependsOn = nil
+	if _, _, err := run.plan(&run.actions[0]); err != nil {
+		t.Errorf("plan(deploy) = %v", err)
+	}
+}
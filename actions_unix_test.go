@@ -0,0 +1,102 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// shCommand runs script with sh -c in dir, discarding its output.
+func shCommand(dir, script string) shellCommand {
+	return shellCommand{Cmd: script, Dir: dir, Shell: "sh", NoLogin: true, Stdout: io.Discard, Stderr: io.Discard}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		script          string
+		want            int
+		wantInterrupted bool
+	}{
+		{"true", 0, false},
+		{"exit 3", 3, false},
+		{"kill -KILL $$", 128 + 9, false},
+		{"kill -TERM $$", 128 + 15, true},
+		{"kill -INT $$", 128 + 2, true},
+	}
+	for _, tt := range tests {
+		err := shCommand(t.TempDir(), tt.script).Run(context.Background())
+		if got := exitCode(err); got != tt.want {
+			t.Errorf("%s: exitCode = %d, want %d", tt.script, got, tt.want)
+		}
+		if got := wasInterrupted(err); got != tt.wantInterrupted {
+			t.Errorf("%s: wasInterrupted = %v, want %v", tt.script, got, tt.wantInterrupted)
+		}
+	}
+}
+
+func TestRunWithPolicyTimeout(t *testing.T) {
+	dir := t.TempDir()
+	action := &RepoAction{Name: "slow", Timeout: "100ms"}
+	// The background sleep is in the same process group, so it's killed too
+	sc := shCommand(dir, "sleep 5 & echo $! > pid; wait")
+
+	start := time.Now()
+	err := runWithPolicy(context.Background(), action, sc, io.Discard)
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("timed-out action took %s", took)
+	}
+	if got := exitCode(err); got != timeoutExitCode {
+		t.Errorf("exitCode = %d (%v), want %d", got, err, timeoutExitCode)
+	}
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("err = %v, want a timeout", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "pid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Killed children may linger as zombies until init reaps them
+	deadline := time.Now().Add(killGracePeriod + 2*time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("background child %d survived the timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRunWithPolicyRetries(t *testing.T) {
+	dir := t.TempDir()
+	attempts := func() int {
+		data, _ := os.ReadFile(filepath.Join(dir, "attempts"))
+		return strings.Count(string(data), "\n")
+	}
+
+	action := &RepoAction{Name: "flaky", Retries: 2, RetryDelay: "10ms"}
+	err := runWithPolicy(context.Background(), action, shCommand(dir, "echo >> attempts; exit 2"), io.Discard)
+	if n := attempts(); n != 3 {
+		t.Errorf("ran %d times, want 3", n)
+	}
+	if exitCode(err) != 2 || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Errorf("err = %v (exit %d), want exit 2 after 3 attempts", err, exitCode(err))
+	}
+
+	// A run stopped by SIGTERM was interrupted, not failed, and isn't retried
+	os.Remove(filepath.Join(dir, "attempts"))
+	err = runWithPolicy(context.Background(), action, shCommand(dir, "echo >> attempts; kill -TERM $$"), io.Discard)
+	if n := attempts(); n != 1 || !wasInterrupted(err) {
+		t.Errorf("interrupted run ran %d times (%v), want once", n, err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultCloneHost        = "github.com"
+	defaultCloneURLTemplate = "git@{host}:{slug}.git"
+	defaultProjectLayout    = "{root}/{repo}"
+)
+
+// cloneTarget describes a repository to clone, resolved from user input.
+type cloneTarget struct {
+	Host  string
+	Owner string
+	Repo  string
+	URL   string
+}
+
+// Slug returns the "owner/repo" form of the target.
+func (t cloneTarget) Slug() string {
+	return t.Owner + "/" + t.Repo
+}
+
+func cmdProjectClone(cfg *Config, args []string) error {
+	var input, dirFlag string
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--dir":
+			if i+1 >= len(args) {
+				return errors.New("--dir requires a value")
+			}
+			i++
+			dirFlag = args[i]
+		case strings.HasPrefix(a, "--dir="):
+			dirFlag = strings.TrimPrefix(a, "--dir=")
+		case input == "":
+			input = a
+		}
+	}
+	if input == "" {
+		return errors.New("usage: colonsh pclone <owner/repo | host/owner/repo | url> [--dir path]")
+	}
+
+	target, err := resolveCloneTarget(cfg.Clone, input)
+	if err != nil {
+		return err
+	}
+
+	pd, root, err := cloneProjectDir(cfg, dirFlag)
+	if err != nil {
+		return err
+	}
+	dest := expandLayout(pd.Layout, root, target)
+
+	if info, err := os.Stat(filepath.Join(dest, ".git")); err == nil && info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Already cloned at %s\n", dest)
+	} else {
+		if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+			return fmt.Errorf("destination %s already exists and is not empty", dest)
+		}
+		if dryRun {
+			fmt.Fprintf(os.Stderr, "[dry-run] would create directory: %s\n", filepath.Dir(dest))
+		} else if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Cloning %s into %s\n", target.URL, dest)
+		cmd := exec.Command("git", "clone", target.URL, dest)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		cmd.Stdin = os.Stdin
+		if err := runCmd(cmd); err != nil {
+			return fmt.Errorf("git clone failed: %w", err)
+		}
+	}
+
+	if err := registerProject(indexedProject{Path: dest, Root: root, Slug: target.Slug()}); err != nil {
+		return fmt.Errorf("cloned, but failed to update project index: %w", err)
+	}
+
+	fmt.Println(dest)
+	return nil
+}
+
+// resolveCloneTarget turns "owner/repo", "host/owner/repo" or a full clone URL into a cloneTarget.
+func resolveCloneTarget(cc *CloneConfig, input string) (cloneTarget, error) {
+	var t cloneTarget
+	if cc == nil {
+		cc = &CloneConfig{}
+	}
+
+	// Full URLs (https://, ssh://, file://, git@host:...) are used verbatim
+	if strings.Contains(input, "://") || strings.HasPrefix(input, "git@") {
+		path := strings.TrimSuffix(strings.TrimRight(input, "/"), ".git")
+		path = strings.ReplaceAll(path, ":", "/")
+		parts := strings.Split(path, "/")
+		if len(parts) < 2 {
+			return t, fmt.Errorf("could not extract owner/repo from %q", input)
+		}
+		t.Owner, t.Repo = parts[len(parts)-2], parts[len(parts)-1]
+		t.URL = input
+		return t, nil
+	}
+
+	parts := strings.Split(strings.Trim(input, "/"), "/")
+	switch {
+	case len(parts) == 2:
+		t.Host = cc.DefaultHost
+		if t.Host == "" {
+			t.Host = defaultCloneHost
+		}
+		t.Owner, t.Repo = parts[0], parts[1]
+	case len(parts) >= 3:
+		// host/owner/repo; nested groups (gitlab) keep everything between host and repo in the owner
+		t.Host = parts[0]
+		t.Owner = strings.Join(parts[1:len(parts)-1], "/")
+		t.Repo = parts[len(parts)-1]
+	default:
+		return t, fmt.Errorf("expected owner/repo, got %q", input)
+	}
+	t.Repo = strings.TrimSuffix(t.Repo, ".git")
+
+	tmpl := cc.Hosts[t.Host]
+	if tmpl == "" {
+		tmpl = cc.URLTemplate
+	}
+	if tmpl == "" {
+		tmpl = defaultCloneURLTemplate
+	}
+	t.URL = strings.NewReplacer(
+		"{host}", t.Host,
+		"{slug}", t.Slug(),
+		"{owner}", t.Owner,
+		"{repo}", t.Repo,
+	).Replace(tmpl)
+	return t, nil
+}
+
+// cloneProjectDir picks the ProjectDir to clone into: --dir, then clone.project_dir, then the first one.
+func cloneProjectDir(cfg *Config, want string) (ProjectDir, string, error) {
+	if len(cfg.ProjectDirs) == 0 {
+		return ProjectDir{}, "", errors.New("no project_dirs configured to clone into")
+	}
+	if want == "" && cfg.Clone != nil {
+		want = cfg.Clone.ProjectDir
+	}
+	if want == "" {
+		pd := cfg.ProjectDirs[0]
+		root, err := expandTilde(pd.Path)
+		return pd, root, err
+	}
+	return matchProjectDir(cfg, want)
+}
+
+// matchProjectDir finds the ProjectDir whose raw path, expanded path or base name equals want.
+func matchProjectDir(cfg *Config, want string) (ProjectDir, string, error) {
+	wantExpanded, err := expandTilde(want)
+	if err != nil {
+		return ProjectDir{}, "", err
+	}
+	for _, pd := range cfg.ProjectDirs {
+		root, err := expandTilde(pd.Path)
+		if err != nil {
+			return ProjectDir{}, "", err
+		}
+		if pd.Path == want || filepath.Clean(root) == filepath.Clean(wantExpanded) || filepath.Base(root) == want {
+			return pd, root, nil
+		}
+	}
+	return ProjectDir{}, "", fmt.Errorf("no project_dirs entry matches %q", want)
+}
+
+// expandLayout renders a ProjectDir layout (default "{root}/{repo}") for a clone target.
+func expandLayout(layout, root string, t cloneTarget) string {
+	if layout == "" {
+		layout = defaultProjectLayout
+	}
+	path := strings.NewReplacer(
+		"{root}", root,
+		"{host}", t.Host,
+		"{owner}", t.Owner,
+		"{repo}", t.Repo,
+		"{slug}", t.Slug(),
+	).Replace(layout)
+	return filepath.Clean(filepath.FromSlash(path))
+}
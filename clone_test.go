@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCloneTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		cc      *CloneConfig
+		input   string
+		want    cloneTarget
+		wantErr bool
+	}{
+		{
+			name:  "owner/repo uses the default host and template",
+			input: "stephenbaidu/colonsh",
+			want:  cloneTarget{Host: "github.com", Owner: "stephenbaidu", Repo: "colonsh", URL: "git@github.com:stephenbaidu/colonsh.git"},
+		},
+		{
+			name:  "configured default host and template",
+			cc:    &CloneConfig{DefaultHost: "gitlab.com", URLTemplate: "https://{host}/{owner}/{repo}.git"},
+			input: "acme/api",
+			want:  cloneTarget{Host: "gitlab.com", Owner: "acme", Repo: "api", URL: "https://gitlab.com/acme/api.git"},
+		},
+		{
+			name:  "per-host template wins over url_template",
+			cc:    &CloneConfig{URLTemplate: "git@{host}:{slug}.git", Hosts: map[string]string{"git.corp": "ssh://git@git.corp:2222/{slug}.git"}},
+			input: "git.corp/team/svc",
+			want:  cloneTarget{Host: "git.corp", Owner: "team", Repo: "svc", URL: "ssh://git@git.corp:2222/team/svc.git"},
+		},
+		{
+			name:  "nested groups stay in the owner",
+			input: "gitlab.com/group/sub/repo.git",
+			want:  cloneTarget{Host: "gitlab.com", Owner: "group/sub", Repo: "repo", URL: "git@gitlab.com:group/sub/repo.git"},
+		},
+		{
+			name:  "file template",
+			cc:    &CloneConfig{URLTemplate: "file:///srv/git/{slug}.git"},
+			input: "acme/api/",
+			want:  cloneTarget{Host: "github.com", Owner: "acme", Repo: "api", URL: "file:///srv/git/acme/api.git"},
+		},
+		{
+			name:  "https URL is used verbatim",
+			input: "https://github.com/acme/api.git",
+			want:  cloneTarget{Owner: "acme", Repo: "api", URL: "https://github.com/acme/api.git"},
+		},
+		{
+			name:  "scp-style URL",
+			input: "git@github.com:acme/api.git",
+			want:  cloneTarget{Owner: "acme", Repo: "api", URL: "git@github.com:acme/api.git"},
+		},
+		{
+			name:  "file URL",
+			input: "file:///srv/git/acme/api.git/",
+			want:  cloneTarget{Owner: "acme", Repo: "api", URL: "file:///srv/git/acme/api.git/"},
+		},
+		{name: "bare name", input: "colonsh", wantErr: true},
+		{name: "empty", input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveCloneTarget(tt.cc, tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("resolveCloneTarget(%q) = %+v, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveCloneTarget(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveCloneTarget(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandLayout(t *testing.T) {
+	target := cloneTarget{Host: "github.com", Owner: "acme", Repo: "api"}
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{"", "/code/api"},
+		{"{root}/{owner}/{repo}", "/code/acme/api"},
+		{"{root}/{host}/{slug}", "/code/github.com/acme/api"},
+		{"{root}//{owner}/../{repo}/", "/code/api"},
+	}
+	for _, tt := range tests {
+		got := expandLayout(tt.layout, "/code", target)
+		if want := filepath.FromSlash(tt.want); got != want {
+			t.Errorf("expandLayout(%q) = %q, want %q", tt.layout, got, want)
+		}
+	}
+}
+
+func TestProjectCloneFileRemote(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+
+	remote := filepath.Join(tmp, "srv", "acme", "api.git")
+	if out, err := exec.Command("git", "init", "--bare", "-q", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v\n%s", err, out)
+	}
+
+	root := filepath.Join(tmp, "code")
+	cfg := &Config{
+		ProjectDirs: []ProjectDir{{Path: root, Layout: "{root}/{owner}/{repo}"}},
+		Clone:       &CloneConfig{URLTemplate: "file://" + filepath.ToSlash(filepath.Join(tmp, "srv")) + "/{slug}.git"},
+	}
+	if err := cmdProjectClone(cfg, []string{"acme/api"}); err != nil {
+		t.Fatalf("cmdProjectClone: %v", err)
+	}
+
+	dest := filepath.Join(root, "acme", "api")
+	if info, err := os.Stat(filepath.Join(dest, ".git")); err != nil || !info.IsDir() {
+		t.Fatalf("expected a clone at %s: %v", dest, err)
+	}
+	index, err := loadProjectIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index) != 1 || index[0].Path != dest || index[0].Slug != "acme/api" {
+		t.Errorf("project index = %+v, want one entry for %s", index, dest)
+	}
+
+	// Cloning again finds the existing clone instead of failing
+	if err := cmdProjectClone(cfg, []string{"acme/api"}); err != nil {
+		t.Errorf("second cmdProjectClone: %v", err)
+	}
+}
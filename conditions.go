@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// conditionCommandTimeout bounds how long a "when" command may take before the
+// action is hidden.
+const conditionCommandTimeout = 5 * time.Second
+
+// conditionEnv is what "when" conditions are checked against, gathered once per :pa run.
+type conditionEnv struct {
+	root   string
+	branch string // Current git branch; empty outside a repository or on a detached HEAD
+}
+
+// newConditionEnv reads the state conditions depend on for ws.
+func newConditionEnv(ws *workspace) conditionEnv {
+	return conditionEnv{root: ws.Root, branch: currentBranch(ws.Root)}
+}
+
+// hiddenActions checks the "when" conditions of every action and returns why each
+// hidden action is unavailable. Command conditions run concurrently.
+func hiddenActions(ws *workspace, actions []RepoAction) map[*RepoAction]string {
+	hidden := make(map[*RepoAction]string)
+	if !slices.ContainsFunc(actions, func(a RepoAction) bool { return a.When != nil }) {
+		return hidden
+	}
+
+	env := newConditionEnv(ws)
+	reasons := make([]string, len(actions))
+	forEachConcurrent(len(actions), func(i int) {
+		if actions[i].When != nil {
+			reasons[i] = env.check(actions[i].When)
+		}
+	})
+	for i, reason := range reasons {
+		if reason != "" {
+			hidden[&actions[i]] = reason
+		}
+	}
+	return hidden
+}
+
+// check returns why c doesn't hold, or "" if it does. Every set condition must hold.
+func (env conditionEnv) check(c *ActionCondition) string {
+	for _, pattern := range c.Files {
+		matches, _ := filepath.Glob(filepath.Join(env.root, filepath.FromSlash(pattern)))
+		if len(matches) == 0 {
+			return "no " + pattern
+		}
+	}
+
+	if c.Branch != "" {
+		if env.branch == "" {
+			return "not on a branch"
+		}
+		if ok, _ := path.Match(c.Branch, env.branch); !ok {
+			return fmt.Sprintf("on branch %s, needs %s", env.branch, c.Branch)
+		}
+	}
+
+	for _, e := range c.Env {
+		name, want, hasValue := strings.Cut(e, "=")
+		got, set := os.LookupEnv(name)
+		switch {
+		case !set || (!hasValue && got == ""):
+			return "$" + name + " is not set"
+		case hasValue && got != want:
+			return fmt.Sprintf("$%s is not %q", name, want)
+		}
+	}
+
+	if len(c.OS) > 0 && !slices.Contains(c.OS, runtime.GOOS) {
+		return fmt.Sprintf("os is %s, needs %s", runtime.GOOS, strings.Join(c.OS, " or "))
+	}
+
+	if c.Command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), conditionCommandTimeout)
+		defer cancel()
+		sc := shellCommand{
+			Cmd:     c.Command,
+			Dir:     env.root,
+			NoLogin: true,
+			Check:   true,
+			Stdout:  io.Discard,
+			Stderr:  io.Discard,
+			Stdin:   bytes.NewReader(nil),
+		}
+		if err := sc.Run(ctx); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Sprintf("`%s` timed out", c.Command)
+			}
+			return fmt.Sprintf("`%s` exited %d", c.Command, exitCode(err))
+		}
+	}
+	return ""
+}
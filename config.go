@@ -2,28 +2,43 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 )
 
 // --- Constants ---
 const (
 	configFileName = "colonsh.json"
+	stateDirName   = "colonsh"
 )
 
 // Config holds the top-level configuration structure.
 type Config struct {
-	Aliases     []Alias      `json:"aliases"`
-	ProjectDirs []ProjectDir `json:"project_dirs"`
-	GitRepos    []GitRepo    `json:"git_repos"`
-	OpenCmd     string       `json:"open_cmd,omitempty"`
+	Aliases       []Alias           `json:"aliases"`
+	ProjectDirs   []ProjectDir      `json:"project_dirs"`
+	GitRepos      []GitRepo         `json:"git_repos"`
+	GlobalActions []RepoAction      `json:"global_actions,omitempty"` // Offered by :pa in every repository and bookmark
+	Bookmarks     []Bookmark        `json:"bookmarks,omitempty"`
+	Clone         *CloneConfig      `json:"clone,omitempty"`
+	Templates     []ProjectTemplate `json:"templates,omitempty"`
+	OpenCmd       string            `json:"open_cmd,omitempty"`
+	Logs          *LogConfig        `json:"logs,omitempty"`
+	Notify        *NotifyConfig     `json:"notify,omitempty"`
+	Hooks         *Hooks            `json:"hooks,omitempty"`
 }
 
 // Alias defines a custom command alias.
 type Alias struct {
 	Name string `json:"name"`
 	Cmd  string `json:"cmd"`
+	ExecOptions
 }
 
 func (a Alias) GetName() string {
@@ -34,21 +49,202 @@ func (a Alias) GetName() string {
 type ProjectDir struct {
 	Path    string   `json:"path"`
 	Exclude []string `json:"exclude"`
+	Tags    []string `json:"tags,omitempty"`
+	Layout  string   `json:"layout,omitempty"` // Where :pclone puts repos, e.g. "{root}/{owner}/{repo}"
 }
 
 // GitRepo defines actions and specific settings for a repository identified by its slug.
+// Slug may also be a glob ("myorg/*") or a regular expression prefixed with "re:".
 type GitRepo struct {
-	Slug    string       `json:"slug"`
-	Name    string       `json:"name"`
-	OpenCmd string       `json:"open_cmd,omitempty"`
-	Actions []RepoAction `json:"actions"`
+	Slug     string       `json:"slug"`
+	Name     string       `json:"name"`
+	OpenCmd  string       `json:"open_cmd,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+	Actions  []RepoAction `json:"actions"`
+	Discover *bool        `json:"discover,omitempty"` // Set to false to skip actions generated from Makefile, package.json, etc.
+	Hooks    *Hooks       `json:"hooks,omitempty"`    // Run after the global hooks in matching repositories
+}
+
+// CloneConfig controls how :pclone turns a slug into a clone URL and where it clones to.
+// URL templates may use {host}, {slug}, {owner} and {repo}.
+type CloneConfig struct {
+	DefaultHost string            `json:"default_host,omitempty"` // Host used for bare "owner/repo" slugs
+	URLTemplate string            `json:"url_template,omitempty"` // e.g. "git@{host}:{slug}.git"
+	Hosts       map[string]string `json:"hosts,omitempty"`        // Per-host URL template overrides
+	ProjectDir  string            `json:"project_dir,omitempty"`  // Path of the ProjectDir to clone into
+}
+
+// ProjectTemplate defines a starting point for :pnew. Source is a local directory
+// or a git URL; {{name}} and {{module}} are substituted in file contents and paths.
+type ProjectTemplate struct {
+	Name       string   `json:"name"`
+	Source     string   `json:"source"`
+	Module     string   `json:"module,omitempty"` // Value of {{module}}, may use {{name}}; defaults to the project name
+	PostCreate []string `json:"post_create,omitempty"`
+}
+
+// Bookmark defines a named directory, git repository or not, that shows up in :pd
+// and can carry its own open command and actions for :po and :pa.
+type Bookmark struct {
+	Name     string       `json:"name"`
+	Path     string       `json:"path"`
+	OpenCmd  string       `json:"open_cmd,omitempty"`
+	Tags     []string     `json:"tags,omitempty"`
+	Actions  []RepoAction `json:"actions,omitempty"`
+	Discover *bool        `json:"discover,omitempty"` // Set to false to skip generated actions
+}
+
+// LogConfig controls capturing :pa output to per-run log files in the state dir.
+type LogConfig struct {
+	Enabled   bool `json:"enabled"`               // Log every run; actions can opt in or out with "log"
+	MaxSizeMB int  `json:"max_size_mb,omitempty"` // Size at which a run's log is rotated; defaults to 10
+	Keep      int  `json:"keep,omitempty"`        // Number of run logs to keep; defaults to 50
+}
+
+// Notification methods for NotifyConfig.Method.
+const (
+	notifyOSC9    = "osc9"
+	notifyOSC777  = "osc777"
+	notifyCommand = "command"
+)
+
+// NotifyConfig controls notifications when long :pa runs finish. In an action it
+// overrides the global settings, and may also be just true or false.
+type NotifyConfig struct {
+	Enabled     *bool  `json:"enabled,omitempty"`      // Defaults to true when notify is set
+	MinDuration string `json:"min_duration,omitempty"` // Only notify for runs at least this long; defaults to 10s
+	Method      string `json:"method,omitempty"`       // osc9 (default), osc777, or command
+	Command     string `json:"command,omitempty"`      // Notifier for the command method; gets COLONSH_* variables
+}
+
+func (n *NotifyConfig) UnmarshalJSON(data []byte) error {
+	var on bool
+	if err := json.Unmarshal(data, &on); err == nil {
+		*n = NotifyConfig{Enabled: &on}
+		return nil
+	}
+	type plain NotifyConfig
+	return json.Unmarshal(data, (*plain)(n))
+}
+
+// Hooks are shell commands run around :pa runs and branch switches. They get the
+// run's context as COLONSH_* environment variables.
+type Hooks struct {
+	PreAction    string `json:"pre_action,omitempty"`    // Before a :pa run; failing cancels it
+	PostAction   string `json:"post_action,omitempty"`   // After every :pa run, failed or not
+	OnFailure    string `json:"on_failure,omitempty"`    // After a :pa run fails
+	PreCheckout  string `json:"pre_checkout,omitempty"`  // Before :gb, :gnb, :main or :master switch branches; failing cancels it
+	PostCheckout string `json:"post_checkout,omitempty"` // After a branch switch succeeds
+}
+
+// validateNotify checks the method and duration of notify settings.
+func validateNotify(n *NotifyConfig) error {
+	if n == nil {
+		return nil
+	}
+	switch n.Method {
+	case "", notifyOSC9, notifyOSC777:
+	case notifyCommand:
+		if n.Command == "" {
+			return errors.New(`notify: method "command" needs a command`)
+		}
+	default:
+		return fmt.Errorf("notify: unknown method %q (use osc9, osc777 or command)", n.Method)
+	}
+	if _, err := parseOptionalDuration(n.MinDuration); err != nil {
+		return fmt.Errorf("notify: min_duration: %w", err)
+	}
+	return nil
 }
 
 // RepoAction defines a single action available within a GitRepo.
 type RepoAction struct {
-	Name string `json:"name"`
-	Cmd  string `json:"cmd"`
-	Dir  string `json:"dir,omitempty"`
+	Name   string        `json:"name"`
+	Key    string        `json:"key,omitempty"`   // Short, stable name for scripting, e.g. `:pa t`
+	Group  string        `json:"group,omitempty"` // Submenu in the :pa picker; "/" nests, e.g. "DB/Migrations"
+	Cmd    string        `json:"cmd"`
+	Dir    string        `json:"dir,omitempty"`
+	Inputs []ActionInput `json:"inputs,omitempty"`
+
+	Packages stringList `json:"packages,omitempty"` // Globs of package dirs, e.g. "services/*"; runs in the current one
+
+	ExecOptions
+
+	DependsOn []string `json:"depends_on,omitempty"` // Names of actions that must run first
+
+	Timeout    string `json:"timeout,omitempty"`     // e.g. "10m"; the process group is killed when it expires
+	Retries    int    `json:"retries,omitempty"`     // Extra attempts after a failure
+	RetryDelay string `json:"retry_delay,omitempty"` // Wait between attempts, e.g. "5s"
+
+	Background bool `json:"background,omitempty"` // Always run detached, as with :pa --bg
+
+	Notify *NotifyConfig `json:"notify,omitempty"` // Notify when the run finishes; overrides the global notify
+
+	Log   *bool    `json:"log,omitempty"`   // Capture output to a log file; overrides logs.enabled
+	Watch []string `json:"watch,omitempty"` // Globs, relative to the root, that trigger reruns with --watch
+
+	When *ActionCondition `json:"when,omitempty"` // Only offer the action when these conditions hold
+
+	Confirm bool   `json:"confirm,omitempty"` // Ask before running
+	Danger  string `json:"danger,omitempty"`  // low, medium or high; high requires typing the repo name
+
+	// A "parallel" action has no Cmd; it runs the actions named in Steps concurrently.
+	Type        string   `json:"type,omitempty"`
+	Steps       []string `json:"steps,omitempty"`
+	MaxParallel int      `json:"max_parallel,omitempty"`
+	FailFast    bool     `json:"fail_fast,omitempty"`
+}
+
+// ExecOptions controls the environment and shell a command runs with. It is shared
+// by repo actions and custom aliases.
+type ExecOptions struct {
+	Env     map[string]string `json:"env,omitempty"`
+	EnvFile stringList        `json:"env_file,omitempty"` // dotenv file(s), relative to the git root
+	Shell   string            `json:"shell,omitempty"`    // bash, sh, zsh, pwsh, or none to exec Argv directly
+	Argv    []string          `json:"argv,omitempty"`     // Program and arguments when Shell is "none"
+	Login   *bool             `json:"login,omitempty"`    // Run a login shell; defaults to true
+}
+
+// hasEffect reports whether any option is set, i.e. the command can't run as a plain shell alias.
+func (o ExecOptions) hasEffect() bool {
+	return len(o.Env) > 0 || len(o.EnvFile) > 0 || o.Shell != "" || len(o.Argv) > 0 || o.Login != nil
+}
+
+// stringList accepts either a single JSON string or an array of strings.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*l = stringList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*l = many
+	return nil
+}
+
+// ActionCondition limits when :pa offers an action. Every condition that is set must hold.
+type ActionCondition struct {
+	Files   stringList `json:"files,omitempty"`   // Paths or globs relative to the root that must exist
+	Branch  string     `json:"branch,omitempty"`  // Glob the current branch must match, e.g. "release/*"
+	Env     stringList `json:"env,omitempty"`     // "NAME" must be set, "NAME=value" must equal value
+	OS      stringList `json:"os,omitempty"`      // Allowed operating systems, e.g. "darwin" or "linux"
+	Command string     `json:"command,omitempty"` // Shell command that must exit 0
+}
+
+// ActionInput declares a value collected before an action runs. It is substituted
+// into the action's Cmd as {{input.<name>}}.
+type ActionInput struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type,omitempty"` // text (default), select, confirm or password
+	Prompt   string   `json:"prompt,omitempty"`
+	Options  []string `json:"options,omitempty"` // Choices for select inputs
+	Default  string   `json:"default,omitempty"`
+	Validate string   `json:"validate,omitempty"` // Regular expression the value must match
 }
 
 // --- Path and Loading Logic ---
@@ -64,6 +260,19 @@ func colonConfigPath() (string, error) {
 	return filepath.Join(home, configFileName), nil
 }
 
+// colonStateDir returns the directory used for colonsh's own state files
+// ($XDG_STATE_HOME/colonsh, falling back to ~/.local/state/colonsh).
+func colonStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, stateDirName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", stateDirName), nil
+}
+
 // loadOrInitConfig loads the config file or creates a default one if it doesn't exist.
 func loadOrInitConfig() (*Config, error) {
 	configPath, err := colonConfigPath()
@@ -79,14 +288,21 @@ func loadOrInitConfig() (*Config, error) {
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			return nil, fmt.Errorf("failed to parse %s: %w", configPath, err)
 		}
+		if err := validateConfig(&cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", configPath, err)
+		}
 		return &cfg, nil
 	}
 
 	// Create default config if not found
+	cfg := defaultConfig()
+	if dryRun {
+		printDryRunWrite("default config", configPath)
+		return cfg, nil
+	}
 	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
 		return nil, err
 	}
-	cfg := defaultConfig()
 	data, err := json.MarshalIndent(cfg, "", "    ")
 	if err != nil {
 		return nil, err
@@ -100,6 +316,146 @@ func loadOrInitConfig() (*Config, error) {
 	return cfg, nil
 }
 
+// validateConfig checks cross-references that JSON decoding can't, such as
+// action dependencies that form a cycle, and patterns that don't compile.
+func validateConfig(cfg *Config) error {
+	if cfg.Logs != nil && (cfg.Logs.MaxSizeMB < 0 || cfg.Logs.Keep < 0) {
+		return errors.New("logs: max_size_mb and keep must not be negative")
+	}
+	if err := validateNotify(cfg.Notify); err != nil {
+		return err
+	}
+	for _, r := range cfg.GitRepos {
+		if expr, ok := strings.CutPrefix(r.Slug, "re:"); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("git_repos %q: invalid slug pattern: %w", r.Slug, err)
+			}
+		} else if _, err := path.Match(r.Slug, ""); err != nil {
+			return fmt.Errorf("git_repos %q: invalid slug pattern", r.Slug)
+		}
+		if err := validateActions(r.Actions); err != nil {
+			return fmt.Errorf("git_repos %q: %w", r.Slug, err)
+		}
+	}
+	if err := validateActions(cfg.GlobalActions); err != nil {
+		return fmt.Errorf("global_actions: %w", err)
+	}
+	for _, b := range cfg.Bookmarks {
+		if err := validateActions(b.Actions); err != nil {
+			return fmt.Errorf("bookmarks %q: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateActions ensures the dependencies within actions are acyclic and that
+// durations, patterns, inputs and danger levels are valid.
+func validateActions(actions []RepoAction) error {
+	if err := checkDependencies(actions); err != nil {
+		return err
+	}
+	for i := range actions {
+		for _, d := range []string{actions[i].Timeout, actions[i].RetryDelay} {
+			if _, err := parseOptionalDuration(d); err != nil {
+				return fmt.Errorf("action %q: %w", actions[i].Name, err)
+			}
+		}
+		if w := actions[i].When; w != nil {
+			for _, g := range append(append([]string{}, w.Files...), w.Branch) {
+				if _, err := path.Match(g, ""); err != nil {
+					return fmt.Errorf("action %q: invalid when pattern %q", actions[i].Name, g)
+				}
+			}
+		}
+		for _, g := range actions[i].Watch {
+			if _, err := path.Match(g, ""); err != nil {
+				return fmt.Errorf("action %q: invalid watch glob %q", actions[i].Name, g)
+			}
+		}
+		for _, g := range actions[i].Packages {
+			if _, err := path.Match(g, ""); err != nil || !filepath.IsLocal(filepath.FromSlash(g)) {
+				return fmt.Errorf("action %q: invalid packages glob %q", actions[i].Name, g)
+			}
+		}
+		if len(actions[i].Packages) == 0 && (strings.Contains(actions[i].Cmd, packagePlaceholder) ||
+			slices.ContainsFunc(actions[i].Argv, func(a string) bool { return strings.Contains(a, packagePlaceholder) })) {
+			return fmt.Errorf("action %q uses %s but has no packages", actions[i].Name, packagePlaceholder)
+		}
+		if g := actions[i].Group; g != "" && slices.Contains(strings.Split(g, "/"), "") {
+			return fmt.Errorf("action %q: invalid group %q", actions[i].Name, g)
+		}
+		if err := validateNotify(actions[i].Notify); err != nil {
+			return fmt.Errorf("action %q: %w", actions[i].Name, err)
+		}
+		for _, in := range actions[i].Inputs {
+			if err := validateInput(in); err != nil {
+				return fmt.Errorf("action %q: %w", actions[i].Name, err)
+			}
+		}
+		if actions[i].Retries < 0 {
+			return fmt.Errorf("action %q: retries must not be negative", actions[i].Name)
+		}
+		switch actions[i].Danger {
+		case "", dangerLow, dangerMedium, dangerHigh:
+		default:
+			return fmt.Errorf("action %q has unknown danger level %q (use low, medium or high)", actions[i].Name, actions[i].Danger)
+		}
+	}
+	return nil
+}
+
+// checkDependencies rejects depends_on cycles and malformed parallel actions among
+// actions. Names that aren't in the list are left to :pa: they may name global
+// actions or ones generated from task files.
+func checkDependencies(actions []RepoAction) error {
+	known := make(map[string]bool, len(actions))
+	for _, a := range actions {
+		known[strings.ToLower(a.Name)] = true
+	}
+	local := func(names []string) []string {
+		return slices.DeleteFunc(slices.Clone(names), func(n string) bool { return !known[strings.ToLower(n)] })
+	}
+	within := make([]RepoAction, len(actions))
+	for i, a := range actions {
+		within[i] = a
+		within[i].DependsOn = local(a.DependsOn)
+		within[i].Steps = local(a.Steps)
+	}
+
+	for i := range within {
+		if _, err := planAction(within, &within[i]); err != nil {
+			return err
+		}
+		if within[i].Type != actionTypeParallel {
+			continue
+		}
+		if len(actions[i].Steps) == 0 {
+			return fmt.Errorf("parallel action %q has no steps", actions[i].Name)
+		}
+		if len(within[i].Steps) > 0 {
+			if _, err := parallelMembers(within, &within[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseOptionalDuration parses a Go duration string such as "90s" or "10m"; "" is zero.
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration %q must not be negative", s)
+	}
+	return d, nil
+}
+
 // defaultConfig generates a basic, example Config structure.
 func defaultConfig() *Config {
 	return &Config{
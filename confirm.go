@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	dangerLow    = "low"
+	dangerMedium = "medium"
+	dangerHigh   = "high"
+)
+
+// errNotConfirmed is returned when the user declines to run a guarded action.
+var errNotConfirmed = errors.New("aborted")
+
+// needsConfirmation reports whether an action must be confirmed before it runs.
+func needsConfirmation(a *RepoAction) bool {
+	return a.Confirm || a.Danger != ""
+}
+
+// actionLabel is the picker label for an action, marking dangerous ones.
+func actionLabel(a *RepoAction) string {
+	label := a.Name
+	if a.Key != "" {
+		label = fmt.Sprintf("%s (%s)", a.Name, a.Key)
+	}
+	switch a.Danger {
+	case dangerHigh:
+		return "‼ " + label + " [danger: high]"
+	case dangerLow, dangerMedium:
+		return "⚠ " + label
+	}
+	return label
+}
+
+// confirmSteps asks for confirmation of every guarded step before anything runs.
+// High-danger actions require typing the workspace name. --yes skips all prompts.
+func (r *actionRun) confirmSteps(steps []*RepoAction) error {
+	if r.args.Yes {
+		return nil
+	}
+	for _, step := range steps {
+		if !needsConfirmation(step) {
+			continue
+		}
+
+		if step.Danger == dangerHigh {
+			want := r.ws.name()
+			var typed string
+			if err := huh.NewInput().
+				Title(fmt.Sprintf("%q is a high-danger action.", step.Name)).
+				Description(fmt.Sprintf("Type %q to continue:", want)).
+				Value(&typed).
+				Run(); err != nil {
+				return err
+			}
+			if strings.TrimSpace(typed) != want {
+				return fmt.Errorf("%w: %q was not confirmed", errNotConfirmed, step.Name)
+			}
+			continue
+		}
+
+		var ok bool
+		if err := huh.NewConfirm().
+			Title(fmt.Sprintf("Run %q?", step.Name)).
+			Description(step.Cmd).
+			Affirmative("Yes").
+			Negative("No").
+			Value(&ok).
+			Run(); err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %q was not confirmed", errNotConfirmed, step.Name)
+		}
+	}
+	return nil
+}
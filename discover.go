@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// discoveredSource is a group of actions generated from a task file in the workspace root.
+type discoveredSource struct {
+	Title   string       // Section header, usually the file name
+	Tool    string       // Qualifies names that clash with another generated source, e.g. "npm test"
+	Actions []RepoAction // Generated actions, named after their targets
+}
+
+var (
+	// makeTargetRe matches rule lines such as "build:" or "test lint: deps", but not
+	// assignments such as "X := 1" or "X ::= 1".
+	makeTargetRe = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_./ -]*?)\s*::?([^=:]|$)`)
+	// justRecipeRe matches recipe headers such as "build:", "@test *args:" or "fmt target='x':".
+	justRecipeRe = regexp.MustCompile(`^@?([A-Za-z][A-Za-z0-9_-]*)(\s[^:]*)?:([^=]|$)`)
+)
+
+// discoverActions generates actions from the Makefile, package.json scripts, justfile
+// recipes, Taskfile tasks, Go test packages and docker compose services found in root.
+func discoverActions(root string) []discoveredSource {
+	var sources []discoveredSource
+	for _, discover := range []func(string) (discoveredSource, bool){
+		discoverMake,
+		discoverPackageScripts,
+		discoverJust,
+		discoverTaskfile,
+		discoverGoTests,
+		discoverCompose,
+	} {
+		if src, ok := discover(root); ok && len(src.Actions) > 0 {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+// firstExisting returns the first of names that exists in root.
+func firstExisting(root string, names ...string) (string, bool) {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// discoverMake lists the explicit targets of the Makefile, skipping special
+// (".PHONY"), pattern ("%.o") and variable-only lines.
+func discoverMake(root string) (discoveredSource, bool) {
+	name, ok := firstExisting(root, "GNUmakefile", "makefile", "Makefile")
+	if !ok {
+		return discoveredSource{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return discoveredSource{}, false
+	}
+
+	src := discoveredSource{Title: name, Tool: "make"}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		m := makeTargetRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		for _, target := range strings.Fields(m[1]) {
+			if strings.ContainsAny(target, "%$") || seen[target] {
+				continue
+			}
+			seen[target] = true
+			src.Actions = append(src.Actions, RepoAction{Name: target, Cmd: "make " + target})
+		}
+	}
+	return src, true
+}
+
+// discoverPackageScripts lists package.json scripts in file order, run with the
+// package manager whose lockfile is present.
+func discoverPackageScripts(root string) (discoveredSource, bool) {
+	data, err := os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		return discoveredSource{}, false
+	}
+	var pkg struct {
+		Scripts json.RawMessage `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil || len(pkg.Scripts) == 0 {
+		return discoveredSource{}, false
+	}
+
+	pm := "npm"
+	for _, lock := range [][2]string{{"pnpm-lock.yaml", "pnpm"}, {"yarn.lock", "yarn"}, {"bun.lock", "bun"}, {"bun.lockb", "bun"}} {
+		if _, err := os.Stat(filepath.Join(root, lock[0])); err == nil {
+			pm = lock[1]
+			break
+		}
+	}
+
+	// Decode token by token to keep the scripts in the order they are written
+	src := discoveredSource{Title: "package.json", Tool: pm}
+	dec := json.NewDecoder(bytes.NewReader(pkg.Scripts))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return discoveredSource{}, false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			break
+		}
+		if script, ok := tok.(string); ok {
+			src.Actions = append(src.Actions, RepoAction{Name: script, Cmd: pm + " run " + shellQuoteArg(script)})
+		}
+	}
+	return src, true
+}
+
+// discoverJust lists the public recipes of the justfile. Recipes starting with "_" or
+// marked [private] are skipped, as are settings, aliases and assignments.
+func discoverJust(root string) (discoveredSource, bool) {
+	name, ok := firstExisting(root, "justfile", "Justfile", ".justfile")
+	if !ok {
+		return discoveredSource{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return discoveredSource{}, false
+	}
+
+	src := discoveredSource{Title: name, Tool: "just"}
+	private := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "[") {
+			// Attributes apply to the recipe that follows
+			private = private || strings.Contains(line, "private")
+			continue
+		}
+		m := justRecipeRe.FindStringSubmatch(line)
+		wasPrivate := private
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			private = false
+		}
+		if m == nil || wasPrivate {
+			continue
+		}
+		switch m[1] {
+		case "set", "alias", "export", "import", "mod":
+			continue
+		}
+		src.Actions = append(src.Actions, RepoAction{Name: m[1], Cmd: "just " + m[1]})
+	}
+	return src, true
+}
+
+// discoverTaskfile lists the tasks of a Taskfile in file order, skipping internal ones.
+func discoverTaskfile(root string) (discoveredSource, bool) {
+	name, ok := firstExisting(root, "Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml")
+	if !ok {
+		return discoveredSource{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return discoveredSource{}, false
+	}
+
+	var doc struct {
+		Tasks yaml.Node `yaml:"tasks"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.Tasks.Kind != yaml.MappingNode {
+		return discoveredSource{}, false
+	}
+
+	src := discoveredSource{Title: name, Tool: "task"}
+	// Mapping nodes hold keys and values alternately
+	for i := 0; i+1 < len(doc.Tasks.Content); i += 2 {
+		task := doc.Tasks.Content[i].Value
+		var opts struct {
+			Internal bool `yaml:"internal"`
+		}
+		// Tasks may also be a bare command string or list; those decode with no options
+		_ = doc.Tasks.Content[i+1].Decode(&opts)
+		if opts.Internal {
+			continue
+		}
+		src.Actions = append(src.Actions, RepoAction{Name: task, Cmd: "task " + task})
+	}
+	return src, true
+}
+
+// discoverGoTests lists "go test" for the whole module and for each package with
+// tests, using the files git knows about. Packages in nested modules are skipped.
+func discoverGoTests(root string) (discoveredSource, bool) {
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err != nil {
+		return discoveredSource{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), projectStatusTimeout)
+	defer cancel()
+	out, err := gitOutputContext(ctx, root, "ls-files", "--cached", "--others", "--exclude-standard", "--", "*_test.go", "*go.mod")
+	if err != nil {
+		return discoveredSource{}, false
+	}
+
+	var modules, dirs []string
+	for _, f := range strings.Split(strings.TrimSpace(out), "\n") {
+		switch {
+		case f == "go.mod":
+		case path.Base(f) == "go.mod":
+			modules = append(modules, path.Dir(f))
+		case strings.HasSuffix(f, "_test.go") && !strings.Contains("/"+f, "/testdata/") && !strings.HasPrefix(f, "vendor/"):
+			dirs = append(dirs, path.Dir(f))
+		}
+	}
+
+	src := discoveredSource{Title: "go test", Tool: "go"}
+	src.Actions = append(src.Actions, RepoAction{Name: "go test ./...", Cmd: "go test ./..."})
+	slices.Sort(dirs)
+	for _, dir := range slices.Compact(dirs) {
+		if slices.ContainsFunc(modules, func(m string) bool { return dir == m || strings.HasPrefix(dir, m+"/") }) {
+			continue
+		}
+		pkg := "./" + dir
+		if dir == "." {
+			pkg = "."
+		}
+		src.Actions = append(src.Actions, RepoAction{Name: "go test " + pkg, Cmd: "go test " + pkg})
+	}
+	return src, true
+}
+
+// discoverCompose generates up, down, logs, restart and shell actions for each
+// service of the compose file, plus up and down for the whole project.
+func discoverCompose(root string) (discoveredSource, bool) {
+	// Same order of preference as docker compose itself
+	name, ok := firstExisting(root, "compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml")
+	if !ok {
+		return discoveredSource{}, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return discoveredSource{}, false
+	}
+
+	var doc struct {
+		Services yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil || doc.Services.Kind != yaml.MappingNode {
+		return discoveredSource{}, false
+	}
+
+	src := discoveredSource{Title: name, Tool: "compose"}
+	src.Actions = append(src.Actions,
+		RepoAction{Name: "up", Cmd: "docker compose up -d"},
+		RepoAction{Name: "down", Cmd: "docker compose down"},
+	)
+	for i := 0; i+1 < len(doc.Services.Content); i += 2 {
+		svc := doc.Services.Content[i].Value
+		q := shellQuoteArg(svc)
+		src.Actions = append(src.Actions,
+			RepoAction{Name: "up " + svc, Cmd: "docker compose up -d " + q},
+			RepoAction{Name: "down " + svc, Cmd: "docker compose down " + q},
+			RepoAction{Name: "logs " + svc, Cmd: "docker compose logs -f " + q},
+			RepoAction{Name: "restart " + svc, Cmd: "docker compose restart " + q},
+			RepoAction{Name: "shell " + svc, Cmd: "docker compose exec " + q + " sh -c " + shellQuoteArg(containerShellScript)},
+		)
+	}
+	return src, true
+}
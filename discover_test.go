@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeFiles creates files in a new temp dir and returns it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// actionCmds returns "name=cmd" for each action, in order.
+func actionCmds(actions []RepoAction) []string {
+	var out []string
+	for _, a := range actions {
+		out = append(out, a.Name+"="+a.Cmd)
+	}
+	return out
+}
+
+func TestDiscoverMake(t *testing.T) {
+	tests := []struct {
+		name     string
+		makefile string
+		want     []string
+	}{
+		{
+			name:     "phony declarations and recipes",
+			makefile: ".PHONY: build test\nbuild: deps\n\tgo build ./...\ntest:\n\tgo test ./...\n",
+			want:     []string{"build=make build", "test=make test"},
+		},
+		{
+			name:     "several targets on one rule and double-colon rules",
+			makefile: "test lint: build\nclean::\n\trm -rf out\n",
+			want:     []string{"test=make test", "lint=make lint", "clean=make clean"},
+		},
+		{
+			name:     "pattern rules and variable targets are skipped",
+			makefile: "%.o: %.c\nout/%.txt: in\n$(BIN): main.go\nsub/dir.done:\n",
+			want:     []string{"sub/dir.done=make sub/dir.done"},
+		},
+		{
+			name:     "assignments are not targets",
+			makefile: "X := 1\nY ::= 2\nZ :::= 3\nW ?= 4\nV += 5\nexport FOO=1\nrun: VAR = 1\n",
+			want:     []string{"run=make run"},
+		},
+		{
+			name:     "repeated targets are listed once",
+			makefile: "build: a\nbuild: b\n# build: commented\n",
+			want:     []string{"build=make build"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, ok := discoverMake(writeFiles(t, map[string]string{"Makefile": tt.makefile}))
+			if !ok {
+				t.Fatal("Makefile not found")
+			}
+			if got := actionCmds(src.Actions); !slices.Equal(got, tt.want) {
+				t.Errorf("actions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// GNUmakefile wins, as with make itself
+	dir := writeFiles(t, map[string]string{"GNUmakefile": "gnu:\n", "Makefile": "plain:\n"})
+	if src, _ := discoverMake(dir); src.Title != "GNUmakefile" || src.Actions[0].Name != "gnu" {
+		t.Errorf("discoverMake used %s, want GNUmakefile", src.Title)
+	}
+	if _, ok := discoverMake(t.TempDir()); ok {
+		t.Error("discoverMake found a Makefile in an empty dir")
+	}
+}
+
+func TestDiscoverJust(t *testing.T) {
+	justfile := `set shell := ["bash", "-c"]
+alias b := build
+version := "1"
+export FOO := "x"
+
+# Build everything
+build:
+    go build ./...
+
+@test *args:
+    go test {{args}}
+
+_helper:
+    true
+
+[private]
+hidden:
+    true
+
+[no-cd]
+fmt target='.':
+    gofmt -w {{target}}
+`
+	src, ok := discoverJust(writeFiles(t, map[string]string{"justfile": justfile}))
+	if !ok {
+		t.Fatal("justfile not found")
+	}
+	want := []string{"build=just build", "test=just test", "fmt=just fmt"}
+	if got := actionCmds(src.Actions); !slices.Equal(got, want) {
+		t.Errorf("actions = %q, want %q", got, want)
+	}
+}
+
+func TestDiscoverPackageScripts(t *testing.T) {
+	scripts := `{"name": "app", "scripts": {"test": "jest", "build": "tsc", "lint:fix": "eslint --fix ."}}`
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+		found bool
+	}{
+		{
+			name:  "npm keeps file order",
+			files: map[string]string{"package.json": scripts},
+			want:  []string{"test=npm run test", "build=npm run build", "lint:fix=npm run lint:fix"},
+			found: true,
+		},
+		{
+			name:  "pnpm lockfile",
+			files: map[string]string{"package.json": scripts, "pnpm-lock.yaml": ""},
+			want:  []string{"test=pnpm run test", "build=pnpm run build", "lint:fix=pnpm run lint:fix"},
+			found: true,
+		},
+		{
+			name:  "yarn lockfile",
+			files: map[string]string{"package.json": `{"scripts": {"dev": "vite"}}`, "yarn.lock": ""},
+			want:  []string{"dev=yarn run dev"},
+			found: true,
+		},
+		{
+			name:  "names are shell-quoted",
+			files: map[string]string{"package.json": `{"scripts": {"build all": "x"}}`},
+			want:  []string{"build all=npm run 'build all'"},
+			found: true,
+		},
+		{name: "no scripts", files: map[string]string{"package.json": `{"name": "lib"}`}},
+		{name: "scripts is not an object", files: map[string]string{"package.json": `{"scripts": ["x"]}`}},
+		{name: "invalid JSON", files: map[string]string{"package.json": `{"scripts": `}},
+		{name: "no package.json", files: map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, ok := discoverPackageScripts(writeFiles(t, tt.files))
+			if ok != tt.found {
+				t.Fatalf("found = %v, want %v", ok, tt.found)
+			}
+			if got := actionCmds(src.Actions); !slices.Equal(got, tt.want) {
+				t.Errorf("actions = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// containerShellScript opens bash in a container when it has one, sh otherwise.
+const containerShellScript = "command -v bash >/dev/null && exec bash || exec sh"
+
+// dockerContainer is one line of `docker ps --format json`.
+type dockerContainer struct {
+	ID     string `json:"ID"`
+	Names  string `json:"Names"`
+	Image  string `json:"Image"`
+	Status string `json:"Status"`
+}
+
+func cmdDockerPS(args []string) error {
+	mode := ""
+	if len(args) > 0 {
+		mode = args[0]
+		if mode != "exec" && mode != "logs" {
+			return fmt.Errorf("unknown argument %q. Usage: colonsh dps [exec|logs]", mode)
+		}
+	}
+
+	containers, err := dockerContainers()
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		fmt.Println("No running containers.")
+		return nil
+	}
+
+	nameWidth, imageWidth := 0, 0
+	for _, c := range containers {
+		nameWidth = max(nameWidth, len(c.Names))
+		imageWidth = max(imageWidth, len(c.Image))
+	}
+	opts := []huh.Option[int]{}
+	for i, c := range containers {
+		label := fmt.Sprintf("%-*s  %-*s  %s", nameWidth, c.Names, imageWidth, c.Image, c.Status)
+		opts = append(opts, huh.NewOption(label, i))
+	}
+
+	selected := -1
+	if err := huh.NewSelect[int]().
+		Title("Select a container").
+		Value(&selected).
+		Options(opts...).
+		Run(); err != nil {
+		return err
+	}
+	if selected < 0 {
+		fmt.Println("No container selected.")
+		return nil
+	}
+	c := containers[selected]
+
+	if mode == "" {
+		if err := huh.NewSelect[string]().
+			Title(c.Names).
+			Options(
+				huh.NewOption("Open a shell", "exec"),
+				huh.NewOption("Tail logs", "logs"),
+			).
+			Value(&mode).
+			Run(); err != nil {
+			return err
+		}
+	}
+
+	var argv []string
+	switch mode {
+	case "exec":
+		argv = []string{"docker", "exec", "-it", c.ID, "sh", "-c", containerShellScript}
+	case "logs":
+		argv = []string{"docker", "logs", "-f", "--tail", "100", c.ID}
+	default:
+		return nil
+	}
+	display := make([]string, len(argv))
+	for i, a := range argv {
+		display[i] = shellQuoteArg(a)
+	}
+	fmt.Printf("Running: %s\n", strings.Join(display, " "))
+	return shellCommand{Shell: "none", Argv: argv}.Run(context.Background())
+}
+
+// dockerContainers lists running containers, one JSON object per line of `docker ps`.
+func dockerContainers() ([]dockerContainer, error) {
+	cmd := exec.Command("docker", "ps", "--format", "json")
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("docker is not installed or not on PATH")
+		}
+		return nil, fmt.Errorf("docker ps failed: %s", cmp.Or(strings.TrimSpace(stderr.String()), err.Error()))
+	}
+
+	var containers []dockerContainer
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var c dockerContainer
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return nil, fmt.Errorf("failed to parse docker ps output: %w", err)
+		}
+		containers = append(containers, c)
+	}
+	return containers, scanner.Err()
+}
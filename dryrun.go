@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// dryRunFlag is the global flag that turns on dry-run mode, like COLONSH_DRY_RUN=1.
+const dryRunFlag = "--dry-run"
+
+// dryRun makes commands and writes that change something print what they would do
+// instead. Read-only git queries still run so the rest of colonsh keeps working.
+var dryRun bool
+
+// parseDryRun turns dry-run mode on from COLONSH_DRY_RUN or a --dry-run flag
+// anywhere before "--", and returns args without the flag.
+func parseDryRun(args []string) []string {
+	if on, err := strconv.ParseBool(os.Getenv("COLONSH_DRY_RUN")); err == nil {
+		dryRun = on
+	}
+	var rest []string
+	for i, a := range args {
+		if a == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if a == dryRunFlag {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	return rest
+}
+
+// printDryRun reports a command dry-run mode skipped: its shell-quoted argv, the
+// directory it would run in and the environment variables it would change.
+// It prints to stderr, which shellFunctionAliases leave free for status.
+func printDryRun(argv []string, dir string, env []string) {
+	quoted := make([]string, len(argv))
+	for i, a := range argv {
+		quoted[i] = shellQuoteArg(a)
+	}
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+	fmt.Fprintf(os.Stderr, "[dry-run] would run: %s\n", strings.Join(quoted, " "))
+	fmt.Fprintf(os.Stderr, "[dry-run]   in: %s\n", dir)
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		if cur, ok := os.LookupEnv(k); ok && cur == v {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[dry-run]   env: %s=%s\n", k, shellQuoteArg(v))
+	}
+}
+
+// printDryRunWrite reports a file write dry-run mode skipped.
+func printDryRunWrite(what, path string) {
+	fmt.Fprintf(os.Stderr, "[dry-run] would write %s: %s\n", what, path)
+}
+
+// runCmd runs cmd, or only reports it in dry-run mode.
+func runCmd(cmd *exec.Cmd) error {
+	if dryRun {
+		printDryRun(cmd.Args, cmd.Dir, cmd.Env)
+		return nil
+	}
+	return cmd.Run()
+}
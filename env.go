@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// applyExecOptions configures sc from opts. Relative env_file paths are resolved
+// against baseDir (normally the git root).
+func applyExecOptions(sc *shellCommand, opts ExecOptions, baseDir string) error {
+	env, err := execEnv(opts, baseDir)
+	if err != nil {
+		return err
+	}
+	sc.Env = env
+	sc.Shell = opts.Shell
+	sc.NoLogin = opts.Login != nil && !*opts.Login
+	return nil
+}
+
+// execEnv returns the KEY=VALUE entries that opts adds to the inherited environment:
+// first every env_file in order, then the env map. Values may reference other
+// variables with $VAR or ${VAR}.
+func execEnv(opts ExecOptions, baseDir string) ([]string, error) {
+	vars := make(map[string]string)
+	lookup := func(key string) string {
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return os.Getenv(key)
+	}
+
+	for _, file := range opts.EnvFile {
+		path, err := expandTilde(file)
+		if err != nil {
+			return nil, err
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read env_file: %w", err)
+		}
+		pairs, err := parseDotenv(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, kv := range pairs {
+			vars[kv[0]] = kv[1]
+		}
+	}
+
+	// Sorted so expansion between env entries is deterministic
+	keys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		vars[k] = os.Expand(opts.Env[k], lookup)
+	}
+
+	env := make([]string, 0, len(vars))
+	for k, v := range vars {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+// parseDotenv parses KEY=VALUE lines. Blank lines, comments and an optional
+// "export " prefix are allowed; values may be single- or double-quoted, and
+// double-quoted values understand \n, \t, \" and \\ escapes.
+func parseDotenv(data []byte) ([][2]string, error) {
+	var pairs [][2]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+		default:
+			// Unquoted values may carry a trailing comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, scanner.Err()
+}
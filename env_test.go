@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][2]string
+		wantErr string
+	}{
+		{
+			name:  "plain, blank lines and comments",
+			input: "# database\nHOST=localhost\n\n  PORT = 5432  \n",
+			want:  [][2]string{{"HOST", "localhost"}, {"PORT", "5432"}},
+		},
+		{
+			name:  "export prefix",
+			input: "export TOKEN=abc\nexport  SPACED=1",
+			want:  [][2]string{{"TOKEN", "abc"}, {"SPACED", "1"}},
+		},
+		{
+			name:  "single quotes are literal",
+			input: `GREETING='hello # not a comment \n'`,
+			want:  [][2]string{{"GREETING", `hello # not a comment \n`}},
+		},
+		{
+			name:  "double quotes understand escapes",
+			input: `MSG="line1\nline2\t\"quoted\" \\n"`,
+			want:  [][2]string{{"MSG", "line1\nline2\t\"quoted\" \\n"}},
+		},
+		{
+			name:  "trailing comment on unquoted values",
+			input: "URL=http://x/#anchor # the url",
+			want:  [][2]string{{"URL", "http://x/#anchor"}},
+		},
+		{
+			name:  "empty and equals in values",
+			input: "EMPTY=\nQUOTED=\"\"\nQUERY=a=b=c",
+			want:  [][2]string{{"EMPTY", ""}, {"QUOTED", ""}, {"QUERY", "a=b=c"}},
+		},
+		{
+			name:  "unbalanced quote is kept",
+			input: `HALF="open`,
+			want:  [][2]string{{"HALF", `"open`}},
+		},
+		{name: "missing equals", input: "A=1\nJUSTAKEY", wantErr: "line 2"},
+		{name: "empty key", input: "=value", wantErr: "line 1"},
+		{name: "space in key", input: "MY KEY=value", wantErr: "line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotenv([]byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseDotenv = %v, %v; want error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDotenv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecEnv(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("HOST=db\nPORT=5432\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".env.local"), []byte("PORT=6543\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("COLONSH_TEST_BASE", "/opt")
+
+	opts := ExecOptions{
+		EnvFile: []string{".env", ".env.local"},
+		Env: map[string]string{
+			"DSN":  "postgres://$HOST:${PORT}/app",
+			"PATH": "$COLONSH_TEST_BASE/bin",
+		},
+	}
+	got, err := execEnv(opts, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"DSN=postgres://db:6543/app", "HOST=db", "PATH=/opt/bin", "PORT=6543"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("execEnv = %q, want %q", got, want)
+	}
+
+	if _, err := execEnv(ExecOptions{EnvFile: []string{"missing.env"}}, dir); err == nil {
+		t.Error("execEnv with a missing env_file succeeded")
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	historyFileName = "history.jsonl"
+	// maxHistoryEntries is how many runs are kept; the file is trimmed once it holds twice as many.
+	maxHistoryEntries = 1000
+	// historyPickerSize is how many recent runs :pa --history offers.
+	historyPickerSize = 50
+)
+
+// historyEntry records one :pa execution.
+type historyEntry struct {
+	Repo       string            `json:"repo,omitempty"`    // "user/repo" slug, if any
+	Root       string            `json:"root"`              // Workspace root the action ran for
+	Action     string            `json:"action"`            // The action that was asked for
+	Actions    []string          `json:"actions,omitempty"` // Actions picked with -m
+	Cmd        string            `json:"cmd"`               // Resolved command, passwords masked
+	Inputs     map[string]string `json:"inputs,omitempty"`  // Resolved inputs, passwords omitted
+	Args       []string          `json:"args,omitempty"`    // Arguments forwarded after "--"
+	Cwd        string            `json:"cwd"`
+	StartedAt  time.Time         `json:"started_at"`
+	DurationMS int64             `json:"duration_ms"`
+	ExitCode   int               `json:"exit_code"`
+	Log        string            `json:"log,omitempty"` // Id for `colonsh logs`, if the output was logged
+}
+
+// historyPath returns the location of the run history in the state dir.
+func historyPath() (string, error) {
+	dir, err := colonStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFileName), nil
+}
+
+// loadHistory reads all recorded runs, oldest first. A missing history is not an error.
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e historyEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			// A torn write from a crashed run shouldn't hide the rest of the history
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// appendHistory records a run. Entries are appended one line at a time and old
+// entries are trimmed occasionally, both under a lock so concurrent runs don't
+// lose each other's entries.
+func appendHistory(e historyEntry) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	entries, err := loadHistory()
+	if err != nil || len(entries) <= 2*maxHistoryEntries {
+		return err
+	}
+	return writeHistory(path, entries[len(entries)-maxHistoryEntries:])
+}
+
+// writeHistory replaces the history file with entries.
+func writeHistory(path string, entries []historyEntry) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), historyFileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// workspaceHistory returns the runs recorded for ws, newest first.
+func workspaceHistory(ws *workspace) ([]historyEntry, error) {
+	entries, err := loadHistory()
+	if err != nil {
+		return nil, err
+	}
+	root := resolvePath(ws.Root)
+	var out []historyEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if resolvePath(entries[i].Root) == root {
+			out = append(out, entries[i])
+		}
+	}
+	return out, nil
+}
+
+// recordRun appends the outcome of this invocation to the history. steps are the
+// command actions that had inputs collected; failures are only reported.
+func (r *actionRun) recordRun(steps []*RepoAction, start time.Time, runErr error) {
+	e := historyEntry{
+		Repo:       r.ws.Slug,
+		Root:       r.ws.Root,
+		Args:       r.args.Extra,
+		StartedAt:  start,
+		DurationMS: time.Since(start).Milliseconds(),
+		ExitCode:   exitCode(runErr),
+	}
+	e.Cwd, _ = os.Getwd()
+	if r.log != nil {
+		e.Log = r.log.meta.ID
+	}
+
+	e.Action = r.name(steps)
+	if r.target != nil {
+		if r.target.Type != actionTypeParallel {
+			_, e.Cmd, _ = r.command(r.target)
+		}
+	} else {
+		var cmds []string
+		for _, step := range steps {
+			e.Actions = append(e.Actions, step.Name)
+			if _, display, err := r.command(step); err == nil {
+				cmds = append(cmds, display)
+			}
+		}
+		e.Cmd = strings.Join(cmds, " & ")
+	}
+
+	// Passwords are never written to disk; a rerun prompts for them again
+	for _, step := range steps {
+		for _, in := range step.Inputs {
+			v, ok := r.inputs[step][in.Name]
+			if !ok || in.Type == inputPassword {
+				continue
+			}
+			if e.Inputs == nil {
+				e.Inputs = make(map[string]string)
+			}
+			e.Inputs[in.Name] = v
+		}
+	}
+
+	if err := appendHistory(e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record run history: %v\n", err)
+	}
+}
+
+// replay returns the arguments that rerun e. Inputs and forwarded arguments given
+// on the command line take precedence over the recorded ones.
+func (e historyEntry) replay(args actionArgs) actionArgs {
+	inputs := maps.Clone(e.Inputs)
+	if inputs == nil {
+		inputs = make(map[string]string)
+	}
+	maps.Copy(inputs, args.Inputs)
+	args.Inputs = inputs
+
+	if args.Extra == nil {
+		args.Extra = e.Args
+	}
+	args.Multi = len(e.Actions) > 0
+	args.Replay = e.Actions
+	if !args.Multi {
+		args.Replay = []string{e.Action}
+	}
+	return args
+}
+
+// lastRun returns the most recent run in ws.
+func lastRun(ws *workspace) (historyEntry, error) {
+	entries, err := workspaceHistory(ws)
+	if err != nil {
+		return historyEntry{}, err
+	}
+	if len(entries) == 0 {
+		return historyEntry{}, errors.New("no previous action runs in this repository")
+	}
+	return entries[0], nil
+}
+
+// selectRun shows the recent runs in ws and returns the chosen one. ok is false if nothing was chosen.
+func selectRun(ws *workspace) (entry historyEntry, ok bool, err error) {
+	entries, err := workspaceHistory(ws)
+	if err != nil {
+		return entry, false, err
+	}
+	if len(entries) == 0 {
+		return entry, false, errors.New("no previous action runs in this repository")
+	}
+	if len(entries) > historyPickerSize {
+		entries = entries[:historyPickerSize]
+	}
+
+	opts := []huh.Option[int]{}
+	for i, e := range entries {
+		opts = append(opts, huh.NewOption(historyLabel(e), i))
+	}
+	selected := -1
+	if err := huh.NewSelect[int]().
+		Title("Rerun an action").
+		Options(opts...).
+		Value(&selected).
+		Run(); err != nil {
+		return entry, false, err
+	}
+	if selected < 0 {
+		return entry, false, nil
+	}
+	return entries[selected], true, nil
+}
+
+// historyLabel is the picker label for a run: age, action, inputs and outcome.
+func historyLabel(e historyEntry) string {
+	status := "✓"
+	if e.ExitCode != 0 {
+		status = fmt.Sprintf("✗ exit %d", e.ExitCode)
+	}
+	label := fmt.Sprintf("%4s  %s", humanAge(time.Since(e.StartedAt)), e.Action)
+	if in := formatInputs(e.Inputs); in != "" {
+		label += "  " + in
+	}
+	if len(e.Args) > 0 {
+		label += "  -- " + strings.Join(e.Args, " ")
+	}
+	return label + "  " + status
+}
+
+// formatInputs renders inputs as sorted name=value pairs.
+func formatInputs(inputs map[string]string) string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(inputs)) {
+		pairs = append(pairs, name+"="+shellQuoteArg(inputs[name]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+func cmdHistory(_ *Config, args []string) error {
+	asJSON := false
+	for _, a := range args {
+		switch a {
+		case "--json":
+			asJSON = true
+		default:
+			return fmt.Errorf("unknown argument %q. Usage: colonsh history [--json]", a)
+		}
+	}
+
+	entries, err := loadHistory()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if entries == nil {
+			entries = []historyEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	for _, e := range entries {
+		where := e.Repo
+		if where == "" {
+			where = filepath.Base(e.Root)
+		}
+		duration := (time.Duration(e.DurationMS) * time.Millisecond).Round(time.Millisecond)
+		fmt.Printf("%s  %-20s  %-20s  exit %-3d  %8s  %s\n",
+			e.StartedAt.Local().Format("2006-01-02 15:04"), where, e.Action, e.ExitCode, duration, e.Cmd)
+	}
+	return nil
+}
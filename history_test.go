@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseReplayArgs(t *testing.T) {
+	for _, args := range [][]string{{"!!"}, {"--last"}} {
+		parsed, err := parseActionArgs(args)
+		if err != nil || !parsed.Last {
+			t.Errorf("parseActionArgs(%q) = %+v, %v; want Last", args, parsed, err)
+		}
+	}
+	if parsed, err := parseActionArgs([]string{"--history", "--", "-v"}); err != nil || !parsed.History || !slices.Equal(parsed.Extra, []string{"-v"}) {
+		t.Errorf("parseActionArgs(--history -- -v) = %+v, %v", parsed, err)
+	}
+	for _, args := range [][]string{{"!!", "test"}, {"--history", "-m"}} {
+		if _, err := parseActionArgs(args); err == nil {
+			t.Errorf("parseActionArgs(%q) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestHistoryEntryReplay(t *testing.T) {
+	e := historyEntry{Action: "deploy", Inputs: map[string]string{"env": "staging", "tag": "v1"}, Args: []string{"--fast"}}
+
+	got := e.replay(actionArgs{Last: true})
+	want := actionArgs{Last: true, Inputs: map[string]string{"env": "staging", "tag": "v1"}, Extra: []string{"--fast"}, Replay: []string{"deploy"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replay = %+v, want %+v", got, want)
+	}
+
+	// The command line wins over the recorded inputs and arguments
+	got = e.replay(actionArgs{History: true, Inputs: map[string]string{"tag": "v2"}, Extra: []string{}})
+	if got.Inputs["tag"] != "v2" || got.Inputs["env"] != "staging" || len(got.Extra) != 0 {
+		t.Errorf("replay with overrides = %+v", got)
+	}
+	if e.Inputs["tag"] != "v1" {
+		t.Error("replay changed the recorded inputs")
+	}
+
+	multi := historyEntry{Action: "lint + test", Actions: []string{"lint", "test"}}
+	if got := multi.replay(actionArgs{Last: true}); !got.Multi || !slices.Equal(got.Replay, []string{"lint", "test"}) {
+		t.Errorf("replay of a -m run = %+v, want lint and test", got)
+	}
+}
+
+func TestLastRun(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+	api, web := filepath.Join(tmp, "api"), filepath.Join(tmp, "web")
+
+	if _, err := lastRun(&workspace{Root: api}); err == nil {
+		t.Error("lastRun with no history succeeded")
+	}
+	for _, e := range []historyEntry{
+		{Root: api, Action: "build"},
+		{Root: api, Action: "test"},
+		{Root: web, Action: "serve"},
+	} {
+		if err := appendHistory(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if e, err := lastRun(&workspace{Root: api}); err != nil || e.Action != "test" {
+		t.Errorf("lastRun(api) = %q, %v; want test", e.Action, err)
+	}
+	if e, err := lastRun(&workspace{Root: web}); err != nil || e.Action != "serve" {
+		t.Errorf("lastRun(web) = %q, %v; want serve", e.Action, err)
+	}
+}
+
+func TestAppendHistoryConcurrentTrim(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", filepath.Join(t.TempDir(), "state"))
+	path, err := historyPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A full history, so the first append trims it while the others append
+	old := make([]historyEntry, 2*maxHistoryEntries)
+	for i := range old {
+		old[i] = historyEntry{Action: fmt.Sprintf("old %d", i), StartedAt: time.Unix(int64(i), 0)}
+	}
+	if err := writeHistory(path, old); err != nil {
+		t.Fatal(err)
+	}
+
+	const runs = 50
+	var wg sync.WaitGroup
+	for i := range runs {
+		wg.Go(func() {
+			if err := appendHistory(historyEntry{Action: fmt.Sprintf("new %d", i)}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+
+	entries, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != maxHistoryEntries+runs-1 {
+		t.Errorf("history has %d entries, want %d", len(entries), maxHistoryEntries+runs-1)
+	}
+	for i := range runs {
+		name := fmt.Sprintf("new %d", i)
+		if !slices.ContainsFunc(entries, func(e historyEntry) bool { return e.Action == name }) {
+			t.Errorf("run %q was lost", name)
+		}
+	}
+}
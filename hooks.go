@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// Hook names, as in the "hooks" config section and COLONSH_HOOK.
+const (
+	hookPreAction    = "pre_action"
+	hookPostAction   = "post_action"
+	hookOnFailure    = "on_failure"
+	hookPreCheckout  = "pre_checkout"
+	hookPostCheckout = "post_checkout"
+)
+
+// command returns the command configured for the named hook, if any.
+func (h *Hooks) command(name string) string {
+	if h == nil {
+		return ""
+	}
+	switch name {
+	case hookPreAction:
+		return h.PreAction
+	case hookPostAction:
+		return h.PostAction
+	case hookOnFailure:
+		return h.OnFailure
+	case hookPreCheckout:
+		return h.PreCheckout
+	case hookPostCheckout:
+		return h.PostCheckout
+	}
+	return ""
+}
+
+// workspaceHooks returns the global hooks followed by those of each git_repos
+// entry matching ws.
+func workspaceHooks(cfg *Config, ws *workspace) []*Hooks {
+	hooks := []*Hooks{cfg.Hooks}
+	for _, r := range ws.Repos {
+		hooks = append(hooks, r.Hooks)
+	}
+	return hooks
+}
+
+// workspaceEnv describes ws to hooks and notifiers as COLONSH_* variables.
+func workspaceEnv(ws *workspace) []string {
+	return []string{
+		"COLONSH_ROOT=" + ws.Root,
+		"COLONSH_REPO_SLUG=" + ws.Slug,
+		"COLONSH_BRANCH=" + currentBranch(ws.Root),
+	}
+}
+
+// runHooks runs the named hook of each of hooks in turn, in the workspace root.
+// The first one to fail stops the rest.
+func runHooks(hooks []*Hooks, name string, ws *workspace, env []string, out io.Writer) error {
+	for _, h := range hooks {
+		cmdStr := h.command(name)
+		if cmdStr == "" {
+			continue
+		}
+		fmt.Fprintf(out, "Running %s hook: %s\n", name, cmdStr)
+		sc := shellCommand{
+			Cmd:    cmdStr,
+			Dir:    ws.Root,
+			Env:    append([]string{"COLONSH_HOOK=" + name}, env...),
+			Stdout: out,
+			Stderr: out,
+		}
+		if err := sc.Run(context.Background()); err != nil {
+			return fmt.Errorf("%s hook failed: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// runHook runs the named action hook for a run of steps. Output goes to the run's
+// log too, once it has one.
+func (r *actionRun) runHook(name string, steps []*RepoAction, vars ...string) error {
+	env := append(workspaceEnv(r.ws), "COLONSH_ACTION="+r.name(steps))
+	return runHooks(r.hooks, name, r.ws, append(env, vars...), r.tee(os.Stdout))
+}
+
+// finishHooks runs post_action, and on_failure if the run failed. Failing hooks
+// are reported but don't change the run's result. Runs the user interrupted
+// don't count as failures.
+func (r *actionRun) finishHooks(steps []*RepoAction, took time.Duration, runErr error) {
+	vars := []string{
+		"COLONSH_EXIT_CODE=" + strconv.Itoa(exitCode(runErr)),
+		"COLONSH_DURATION=" + strconv.Itoa(int(took.Seconds())),
+	}
+	names := []string{hookPostAction}
+	if runErr != nil && !wasInterrupted(runErr) {
+		names = append(names, hookOnFailure)
+	}
+	for _, name := range names {
+		if err := r.runHook(name, steps, vars...); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+}
+
+// gitCheckout switches branches with `git checkout args...`, running the
+// pre_checkout and post_checkout hooks around it. branch is the branch being
+// switched to. The hooks are those of the git repository, even inside a bookmark.
+func gitCheckout(cfg *Config, branch string, args ...string) error {
+	root, err := gitRoot()
+	if err != nil {
+		return errors.New("this is not a git repository")
+	}
+	slug, _ := gitRepoSlug()
+	ws := &workspace{Root: root, Slug: slug, Repos: matchingRepos(cfg, slug)}
+	hooks := workspaceHooks(cfg, ws)
+	env := []string{
+		"COLONSH_ROOT=" + ws.Root,
+		"COLONSH_REPO_SLUG=" + ws.Slug,
+		"COLONSH_BRANCH=" + branch,
+		"COLONSH_PREVIOUS_BRANCH=" + currentBranch(ws.Root),
+	}
+	if err := runHooks(hooks, hookPreCheckout, ws, env, os.Stdout); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", append([]string{"checkout"}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := runCmd(cmd); err != nil {
+		return err
+	}
+	if err := runHooks(hooks, hookPostCheckout, ws, env, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitCheckoutHooksInsideBookmark(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	git("commit", "-q", "--allow-empty", "-m", "init")
+	git("branch", "feature")
+	git("remote", "add", "origin", "git@github.com:acme/api.git")
+
+	sub := filepath.Join(repo, "web")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	record := `echo "$COLONSH_HOOK $COLONSH_REPO_SLUG $COLONSH_PREVIOUS_BRANCH $COLONSH_BRANCH" >> ` + shellQuoteArg(out)
+	cfg := &Config{
+		GitRepos:  []GitRepo{{Slug: "acme/*", Hooks: &Hooks{PreCheckout: record, PostCheckout: record}}},
+		Bookmarks: []Bookmark{{Name: "web", Path: sub}},
+	}
+	t.Setenv("SHELL", "/bin/sh")
+	t.Chdir(sub)
+
+	if err := gitCheckout(cfg, "feature", "feature"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("hooks didn't run: %v", err)
+	}
+	want := "pre_checkout acme/api main feature\npost_checkout acme/api main feature\n"
+	if got := string(data); got != want {
+		t.Errorf("hooks ran as:\n%s\nwant:\n%s", got, want)
+	}
+	if branch := currentBranch(repo); branch != "feature" {
+		t.Errorf("branch = %q, want feature", branch)
+	}
+
+	// A failing pre_checkout hook keeps the branch
+	cfg.GitRepos[0].Hooks.PreCheckout = "exit 1"
+	if err := gitCheckout(cfg, "main", "main"); err == nil || !strings.Contains(err.Error(), "pre_checkout") {
+		t.Errorf("gitCheckout = %v, want a pre_checkout error", err)
+	}
+	if branch := currentBranch(repo); branch != "feature" {
+		t.Errorf("branch = %q after a failed pre_checkout, want feature", branch)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	inputText     = "text"
+	inputSelect   = "select"
+	inputConfirm  = "confirm"
+	inputPassword = "password"
+)
+
+// parseInputArg splits a "name=value" command-line argument into its parts.
+func parseInputArg(arg string) (name, value string, ok bool) {
+	name, value, ok = strings.Cut(arg, "=")
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// resolveInputs validates the inputs passed on the command line and prompts for
+// the rest. It returns the final value of every declared input.
+func resolveInputs(action *RepoAction, provided map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(action.Inputs))
+
+	for name := range provided {
+		if !slices.ContainsFunc(action.Inputs, func(in ActionInput) bool { return in.Name == name }) {
+			var names []string
+			for _, in := range action.Inputs {
+				names = append(names, in.Name)
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("action %q takes no inputs, got %q", action.Name, name)
+			}
+			return nil, fmt.Errorf("action %q has no input %q (inputs: %s)", action.Name, name, strings.Join(names, ", "))
+		}
+	}
+
+	var fields []huh.Field
+	texts := make([]string, len(action.Inputs))
+	bools := make([]bool, len(action.Inputs))
+
+	for i, in := range action.Inputs {
+		if v, ok := provided[in.Name]; ok {
+			v, err := normalizeInput(in, v)
+			if err != nil {
+				return nil, err
+			}
+			values[in.Name] = v
+			continue
+		}
+
+		title := in.Prompt
+		if title == "" {
+			title = in.Name
+		}
+		validate := func(v string) error {
+			_, err := normalizeInput(in, v)
+			return err
+		}
+
+		switch in.Type {
+		case inputSelect:
+			texts[i] = in.Default
+			fields = append(fields, huh.NewSelect[string]().
+				Title(title).
+				Options(huh.NewOptions(in.Options...)...).
+				Value(&texts[i]))
+		case inputConfirm:
+			bools[i], _ = parseYesNo(in.Default)
+			fields = append(fields, huh.NewConfirm().
+				Title(title).
+				Affirmative("Yes").
+				Negative("No").
+				Value(&bools[i]))
+		case inputPassword:
+			texts[i] = in.Default
+			fields = append(fields, huh.NewInput().
+				Title(title).
+				EchoMode(huh.EchoModePassword).
+				Validate(validate).
+				Value(&texts[i]))
+		default:
+			texts[i] = in.Default
+			fields = append(fields, huh.NewInput().
+				Title(title).
+				Validate(validate).
+				Value(&texts[i]))
+		}
+	}
+
+	if len(fields) > 0 {
+		if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+			return nil, err
+		}
+		for i, in := range action.Inputs {
+			if _, done := values[in.Name]; done {
+				continue
+			}
+			if in.Type == inputConfirm {
+				values[in.Name] = strconv.FormatBool(bools[i])
+			} else {
+				values[in.Name] = texts[i]
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// validateInput checks an input's declaration when the config is loaded, so
+// mistakes don't show up only once earlier steps of a run have executed.
+func validateInput(in ActionInput) error {
+	switch in.Type {
+	case "", inputText, inputConfirm, inputPassword:
+	case inputSelect:
+		if len(in.Options) == 0 {
+			return fmt.Errorf("input %q: select needs options", in.Name)
+		}
+	default:
+		return fmt.Errorf("input %q has unknown type %q (use text, select, confirm or password)", in.Name, in.Type)
+	}
+	if _, err := regexp.Compile(in.Validate); err != nil {
+		return fmt.Errorf("input %q has an invalid validate pattern: %w", in.Name, err)
+	}
+	return nil
+}
+
+// normalizeInput checks a value against the input's type and validation rules,
+// returning its canonical form (e.g. "true"/"false" for confirm inputs).
+func normalizeInput(in ActionInput, v string) (string, error) {
+	switch in.Type {
+	case inputConfirm:
+		b, err := parseYesNo(v)
+		if err != nil {
+			return "", fmt.Errorf("input %q expects yes or no, got %q", in.Name, v)
+		}
+		return strconv.FormatBool(b), nil
+	case inputSelect:
+		if !slices.Contains(in.Options, v) {
+			return "", fmt.Errorf("input %q must be one of: %s", in.Name, strings.Join(in.Options, ", "))
+		}
+	}
+
+	if in.Validate != "" {
+		re, err := regexp.Compile(in.Validate)
+		if err != nil {
+			return "", fmt.Errorf("input %q has an invalid validate pattern: %w", in.Name, err)
+		}
+		if !re.MatchString(v) {
+			return "", fmt.Errorf("input %q must match %s", in.Name, in.Validate)
+		}
+	}
+	return v, nil
+}
+
+// parseYesNo accepts the usual boolean spellings plus yes/no and y/n.
+func parseYesNo(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+// substituteInputs replaces {{input.<name>}} placeholders with shell-quoted values.
+// When mask is set, password inputs are replaced with asterisks (for display).
+func substituteInputs(cmdStr string, inputs []ActionInput, values map[string]string, mask bool) string {
+	return replaceInputs(cmdStr, inputs, values, mask, shellQuoteArg)
+}
+
+// substituteInputsRaw is substituteInputs without shell quoting, for argv elements.
+func substituteInputsRaw(arg string, inputs []ActionInput, values map[string]string, mask bool) string {
+	return replaceInputs(arg, inputs, values, mask, func(s string) string { return s })
+}
+
+func replaceInputs(s string, inputs []ActionInput, values map[string]string, mask bool, quote func(string) string) string {
+	for _, in := range inputs {
+		v := quote(values[in.Name])
+		if mask && in.Type == inputPassword {
+			v = "****"
+		}
+		s = strings.ReplaceAll(s, "{{input."+in.Name+"}}", v)
+	}
+	return s
+}
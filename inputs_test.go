@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateInput(t *testing.T) {
+	tests := []struct {
+		in      ActionInput
+		wantErr string
+	}{
+		{in: ActionInput{Name: "env"}},
+		{in: ActionInput{Name: "env", Type: inputSelect, Options: []string{"staging", "prod"}}},
+		{in: ActionInput{Name: "ok", Type: inputConfirm}},
+		{in: ActionInput{Name: "token", Type: inputPassword, Validate: `^[a-z0-9]{8,}$`}},
+		{in: ActionInput{Name: "env", Type: "slect"}, wantErr: `unknown type "slect"`},
+		{in: ActionInput{Name: "env", Type: inputSelect}, wantErr: "select needs options"},
+		{in: ActionInput{Name: "n", Validate: "([0-9]"}, wantErr: "invalid validate pattern"},
+	}
+	for _, tt := range tests {
+		err := validateInput(tt.in)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateInput(%+v) = %v", tt.in, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("validateInput(%+v) = %v, want error containing %q", tt.in, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNormalizeInput(t *testing.T) {
+	tests := []struct {
+		in      ActionInput
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{ActionInput{Name: "ok", Type: inputConfirm}, "y", "true", false},
+		{ActionInput{Name: "ok", Type: inputConfirm}, "No", "false", false},
+		{ActionInput{Name: "ok", Type: inputConfirm}, "maybe", "", true},
+		{ActionInput{Name: "env", Type: inputSelect, Options: []string{"staging", "prod"}}, "prod", "prod", false},
+		{ActionInput{Name: "env", Type: inputSelect, Options: []string{"staging", "prod"}}, "dev", "", true},
+		{ActionInput{Name: "n", Validate: `^\d+$`}, "42", "42", false},
+		{ActionInput{Name: "n", Validate: `^\d+$`}, "4x", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeInput(tt.in, tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeInput(%q, %q) = %q, %v; want %q, error %v", tt.in.Name, tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
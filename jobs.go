@@ -0,0 +1,283 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"time"
+)
+
+const (
+	// jobStartTimeout is how long :pa --bg waits for a background job to start its log.
+	jobStartTimeout = 5 * time.Second
+	// jobStopTimeout is how long `colonsh jobs stop` waits for a job to wind down.
+	jobStopTimeout = 10 * time.Second
+)
+
+// jobSpec is what :pa --bg hands to the detached `colonsh job` process. It is sent
+// on the job's stdin so inputs, passwords included, never touch the disk or argv.
+type jobSpec struct {
+	Action string                       `json:"action"`
+	Inputs map[string]map[string]string `json:"inputs,omitempty"` // Resolved inputs per step name
+	Extra  []string                     `json:"extra,omitempty"`
+
+	Packages    map[string]string `json:"packages,omitempty"` // Resolved package per step name
+	AllPackages bool              `json:"all_packages,omitempty"`
+}
+
+// loadJobs returns the background jobs that are still running, oldest first.
+func loadJobs() ([]logMeta, error) {
+	dir, err := logsDir()
+	if err != nil {
+		return nil, err
+	}
+	metas, err := loadLogMetas(dir)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(metas, func(m logMeta) bool {
+		return !m.Background || m.status() != "running"
+	}), nil
+}
+
+// runningJobs returns the background jobs running in ws, by action name.
+func runningJobs(ws *workspace) map[string]logMeta {
+	jobs := make(map[string]logMeta)
+	all, err := loadJobs()
+	if err != nil {
+		return jobs
+	}
+	root := resolvePath(ws.Root)
+	for _, m := range all {
+		if resolvePath(m.Root) == root {
+			jobs[m.Action] = m
+		}
+	}
+	return jobs
+}
+
+// checkNotRunning refuses to start a second background job for the target.
+func (r *actionRun) checkNotRunning() error {
+	if job, ok := r.jobs[r.target.Name]; ok {
+		return fmt.Errorf("action %q is already running as job %s; stop it with `colonsh jobs stop %s`", r.target.Name, job.ID, job.ID)
+	}
+	return nil
+}
+
+// startJob runs the target in a detached `colonsh job` process that outlives this one
+// and the terminal. It returns once the job has created its log.
+func (r *actionRun) startJob(steps []*RepoAction) error {
+	spec := jobSpec{
+		Action:      r.target.Name,
+		Extra:       r.args.Extra,
+		Inputs:      make(map[string]map[string]string),
+		Packages:    make(map[string]string),
+		AllPackages: r.args.AllPackages,
+	}
+	for _, step := range steps {
+		spec.Inputs[step.Name] = r.inputs[step]
+		if pkg := r.packages[step]; pkg != "" {
+			spec.Packages[step.Name] = pkg
+		}
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "job")
+	cmd.Dir = r.ws.Root
+	detachProcess(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start background job: %w", err)
+	}
+	_, err = stdin.Write(data)
+	stdin.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start background job: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.After(jobStartTimeout)
+	for done := false; ; {
+		if m, ok := findJobByPid(cmd.Process.Pid); ok {
+			switch status := m.status(); {
+			case status == "running":
+			case m.ExitCode != 0:
+				return &exitError{code: m.ExitCode, err: fmt.Errorf("background job %s failed right away (%s); see its output with: colonsh logs %s", m.ID, status, m.ID)}
+			default:
+				fmt.Printf("Job %s (%q) already finished. See its output with: colonsh logs %s\n", m.ID, r.target.Name, m.ID)
+				return nil
+			}
+			fmt.Printf("Started %q in the background as job %s (pid %d)\n", r.target.Name, m.ID, m.Pid)
+			fmt.Printf("Follow it with: colonsh jobs attach %s\nStop it with:   colonsh jobs stop %s\n", m.ID, m.ID)
+			return nil
+		}
+		if done {
+			return errors.New("background job exited before it started; run the action without --bg to see why")
+		}
+		select {
+		case <-exited:
+			// Look once more: the job may have finished as soon as it started
+			done = true
+		case <-deadline:
+			return fmt.Errorf("background job (pid %d) didn't start within %s", cmd.Process.Pid, jobStartTimeout)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// findJobByPid finds the log a background job started by pid created.
+func findJobByPid(pid int) (logMeta, bool) {
+	dir, err := logsDir()
+	if err != nil {
+		return logMeta{}, false
+	}
+	metas, _ := loadLogMetas(dir)
+	i := slices.IndexFunc(metas, func(m logMeta) bool { return m.Background && m.Pid == pid })
+	if i < 0 {
+		return logMeta{}, false
+	}
+	return metas[i], true
+}
+
+// cmdRunJob is the detached process behind :pa --bg. It runs in the workspace root,
+// reads its jobSpec from stdin and runs the action like :pa would, always logged.
+func cmdRunJob(cfg *Config) error {
+	var spec jobSpec
+	if err := json.NewDecoder(os.Stdin).Decode(&spec); err != nil {
+		return fmt.Errorf("failed to read job: %w", err)
+	}
+	// Nothing types into a background job; don't let actions wait on stdin
+	if devNull, err := os.Open(os.DevNull); err == nil {
+		os.Stdin = devNull
+	}
+
+	ws, err := currentWorkspace(cfg)
+	if err != nil {
+		return err
+	}
+	actions, sections := ws.actions()
+	found, err := actionsByName(actions, []string{spec.Action})
+	if err != nil {
+		return err
+	}
+	run := &actionRun{
+		ws:       ws,
+		actions:  actions,
+		sections: sections,
+		args:     actionArgs{Extra: spec.Extra, AllPackages: spec.AllPackages},
+		target:   found[0],
+		inputs:   make(map[*RepoAction]map[string]string),
+		packages: make(map[*RepoAction]string),
+		origin:   make(map[*RepoAction]*RepoAction),
+		notify:   cfg.Notify,
+		hooks:    workspaceHooks(cfg, ws),
+	}
+	plan, steps, err := run.plan(found[0])
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		run.inputs[step] = spec.Inputs[step.Name]
+		if pkg := spec.Packages[step.Name]; pkg != "" {
+			run.packages[step] = pkg
+		}
+	}
+
+	// `colonsh jobs stop` sends SIGTERM; finish the log instead of dying with it
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if run.log, err = startRunLog(cfg.Logs, ws, run.name(steps), true); err != nil {
+		return err
+	}
+	start := time.Now()
+	err = run.runPlan(ctx, plan)
+	run.finish(steps, start, err)
+	return err
+}
+
+func cmdJobs(_ *Config, args []string) error {
+	jobs, err := loadJobs()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		if len(jobs) == 0 {
+			fmt.Println("No background jobs running. Start one with :pa --bg.")
+			return nil
+		}
+		slices.SortStableFunc(jobs, func(a, b logMeta) int {
+			return cmp.Compare(a.where(), b.where())
+		})
+		for _, m := range jobs {
+			fmt.Printf("%s  %4s  pid %-7d  %-20s  %s\n", m.ID, humanAge(time.Since(m.StartedAt)), m.Pid, m.where(), m.Action)
+		}
+		return nil
+	}
+
+	if len(args) != 2 || (args[0] != "stop" && args[0] != "attach") {
+		return errors.New("usage: colonsh jobs [stop|attach <id>]")
+	}
+	if len(jobs) == 0 {
+		return errors.New("no background jobs running")
+	}
+	m, err := findLog(jobs, args[1])
+	if err != nil {
+		return err
+	}
+	dir, err := logsDir()
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(dir, m.ID)
+
+	if args[0] == "attach" {
+		fmt.Fprintf(os.Stderr, "Attached to %s (%s). Ctrl-C detaches; the job keeps running.\n", m.ID, m.Action)
+		return followLog(base, os.Stdout)
+	}
+
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "[dry-run] would stop job %s (pid %d)\n", m.ID, m.Pid)
+		return nil
+	}
+	if err := stopProcess(m.Pid); err != nil {
+		return fmt.Errorf("failed to stop job %s: %w", m.ID, err)
+	}
+	deadline := time.Now().Add(jobStopTimeout)
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(base + ".json")
+		if err != nil {
+			return err
+		}
+		var cur logMeta
+		if err := json.Unmarshal(data, &cur); err != nil {
+			return err
+		}
+		if status := cur.status(); status != "running" {
+			fmt.Printf("Stopped job %s (%s): %s\n", m.ID, m.Action, status)
+			return nil
+		}
+		time.Sleep(logPollInterval)
+	}
+	return fmt.Errorf("job %s (pid %d) is still running", m.ID, m.Pid)
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	logsDirName      = "logs"
+	defaultLogSizeMB = 10
+	defaultLogKeep   = 50
+	// logPollInterval is how often `colonsh logs --follow` checks for new output.
+	logPollInterval = 250 * time.Millisecond
+)
+
+// logMeta describes a logged run. It is written next to the log when the run
+// starts and updated when it finishes.
+type logMeta struct {
+	ID         string    `json:"id"`
+	Repo       string    `json:"repo,omitempty"`
+	Root       string    `json:"root"`
+	Action     string    `json:"action"`
+	Pid        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	ExitCode   int       `json:"exit_code"`
+	Background bool      `json:"background,omitempty"` // Started with :pa --bg; Pid is the job's supervisor
+}
+
+// status is "running", "ok", "exit N", or "interrupted" for runs whose process
+// went away without finishing the log.
+func (m logMeta) status() string {
+	switch {
+	case m.FinishedAt.IsZero() && processAlive(m.Pid):
+		return "running"
+	case m.FinishedAt.IsZero():
+		return "interrupted"
+	case m.ExitCode == 0:
+		return "ok"
+	default:
+		return fmt.Sprintf("exit %d", m.ExitCode)
+	}
+}
+
+// where names the workspace of the run: its repository slug, or the root's base name.
+func (m logMeta) where() string {
+	if m.Repo != "" {
+		return m.Repo
+	}
+	return filepath.Base(m.Root)
+}
+
+// runLog is the log file of one :pa run. Output beyond maxSize is rotated into
+// "<id>.log.1", so a run keeps at most two segments.
+type runLog struct {
+	mu      sync.Mutex
+	dir     string
+	meta    logMeta
+	f       *os.File
+	size    int64
+	maxSize int64
+}
+
+// logsDir returns the directory holding run logs in the state dir.
+func logsDir() (string, error) {
+	dir, err := colonStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, logsDirName), nil
+}
+
+// logEnabled decides whether a run of steps is logged: any step with "log": true
+// turns it on, otherwise logs.enabled applies unless a step opts out.
+func logEnabled(lc *LogConfig, steps []*RepoAction) bool {
+	enabled := lc != nil && lc.Enabled
+	for _, step := range steps {
+		if step.Log != nil {
+			if *step.Log {
+				return true
+			}
+			enabled = false
+		}
+	}
+	return enabled
+}
+
+// startRunLog creates the log for a new run and prunes old ones.
+func startRunLog(lc *LogConfig, ws *workspace, action string, background bool) (*runLog, error) {
+	dir, err := logsDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	sizeMB, keep := defaultLogSizeMB, defaultLogKeep
+	if lc != nil && lc.MaxSizeMB > 0 {
+		sizeMB = lc.MaxSizeMB
+	}
+	if lc != nil && lc.Keep > 0 {
+		keep = lc.Keep
+	}
+	// Make room for the run we're about to add
+	if err := pruneLogs(dir, keep-1); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("%s-%d", now.Format("20060102-150405"), os.Getpid())
+	// Watch mode can start several runs from one process within a second
+	for n := 2; ; n++ {
+		if _, err := os.Stat(filepath.Join(dir, id+".json")); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d-%d", now.Format("20060102-150405"), os.Getpid(), n)
+	}
+	l := &runLog{
+		dir:     dir,
+		maxSize: int64(sizeMB) << 20,
+		meta: logMeta{
+			ID:         id,
+			Repo:       ws.Slug,
+			Root:       ws.Root,
+			Action:     action,
+			Pid:        os.Getpid(),
+			StartedAt:  now,
+			Background: background,
+		},
+	}
+	if l.f, err = os.OpenFile(l.path(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600); err != nil {
+		return nil, err
+	}
+	if err := l.writeMeta(); err != nil {
+		l.f.Close()
+		return nil, err
+	}
+	fmt.Fprintf(l, "# %s in %s, started %s\n", action, ws.Root, now.Format(time.RFC3339))
+	return l, nil
+}
+
+func (l *runLog) path() string {
+	return filepath.Join(l.dir, l.meta.ID+".log")
+}
+
+// Write appends to the log, rotating it once it would exceed maxSize.
+func (l *runLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return len(p), nil
+	}
+	if l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			// Stop logging rather than failing the output it copies
+			fmt.Fprintf(os.Stderr, "Warning: stopped logging to %s: %v\n", l.meta.ID, err)
+			return len(p), nil
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current segment to "<id>.log.1", replacing any older one.
+// If that fails, the log is left closed and later writes are dropped.
+func (l *runLog) rotate() error {
+	err := l.f.Close()
+	l.f = nil
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(l.path(), l.path()+".1"); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	l.f, l.size = f, 0
+	return nil
+}
+
+// finish closes the log and records the run's outcome.
+func (l *runLog) finish(runErr error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+	l.meta.FinishedAt = time.Now()
+	l.meta.ExitCode = exitCode(runErr)
+	if err := l.writeMeta(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to finish log %s: %v\n", l.meta.ID, err)
+	}
+}
+
+func (l *runLog) writeMeta() error {
+	data, err := json.MarshalIndent(l.meta, "", "    ")
+	if err != nil {
+		return err
+	}
+	// Replace the file in one step: followLog and `jobs stop` poll it while the run is going
+	tmp, err := os.CreateTemp(l.dir, l.meta.ID+".json.*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(l.dir, l.meta.ID+".json"))
+}
+
+// loadLogMetas returns the logged runs in dir, oldest first.
+func loadLogMetas(dir string) ([]logMeta, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var metas []logMeta
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var m logMeta
+		if json.Unmarshal(data, &m) == nil && m.ID != "" {
+			metas = append(metas, m)
+		}
+	}
+	slices.SortFunc(metas, func(a, b logMeta) int { return a.StartedAt.Compare(b.StartedAt) })
+	return metas, nil
+}
+
+// pruneLogs removes the oldest finished runs so at most keep remain.
+func pruneLogs(dir string, keep int) error {
+	metas, err := loadLogMetas(dir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(metas)-max(keep, 0); i++ {
+		if metas[i].status() == "running" {
+			continue
+		}
+		base := filepath.Join(dir, metas[i].ID)
+		for _, p := range []string{base + ".log", base + ".log.1", base + ".json"} {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func cmdLogs(_ *Config, args []string) error {
+	var id string
+	follow := false
+	for _, a := range args {
+		switch {
+		case a == "--follow" || a == "-f":
+			follow = true
+		case id == "" && !strings.HasPrefix(a, "-"):
+			id = a
+		default:
+			return fmt.Errorf("unknown argument %q. Usage: colonsh logs [id [--follow]]", a)
+		}
+	}
+
+	dir, err := logsDir()
+	if err != nil {
+		return err
+	}
+	metas, err := loadLogMetas(dir)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		if follow {
+			return errors.New("--follow needs a log id; run `colonsh logs` to list them")
+		}
+		if len(metas) == 0 {
+			fmt.Println("No logged runs. Enable logs in colonsh.json or set \"log\": true on an action.")
+			return nil
+		}
+		for _, m := range slices.Backward(metas) {
+			fmt.Printf("%s  %4s  %-11s  %-20s  %s\n", m.ID, humanAge(time.Since(m.StartedAt)), m.status(), m.where(), m.Action)
+		}
+		return nil
+	}
+
+	m, err := findLog(metas, id)
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(dir, m.ID)
+	if follow {
+		return followLog(base, os.Stdout)
+	}
+	for _, p := range []string{base + ".log.1", base + ".log"} {
+		if err := copyFile(os.Stdout, p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// findLog matches id against run ids, accepting any unique substring.
+func findLog(metas []logMeta, id string) (logMeta, error) {
+	var found []logMeta
+	for _, m := range metas {
+		if m.ID == id {
+			return m, nil
+		}
+		if strings.Contains(m.ID, id) {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return logMeta{}, fmt.Errorf("no log matches %q", id)
+	case 1:
+		return found[0], nil
+	default:
+		var ids []string
+		for _, m := range found {
+			ids = append(ids, m.ID)
+		}
+		return logMeta{}, fmt.Errorf("log id %q is ambiguous, matches: %s", id, strings.Join(ids, ", "))
+	}
+}
+
+// copyFile writes the contents of path to w.
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// followLog prints the log at base+".log" and keeps printing new output until the
+// run finishes. Rotation is detected by the path pointing at a different file.
+func followLog(base string, w io.Writer) error {
+	path := base + ".log"
+	var f *os.File
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	for {
+		if f == nil {
+			var err error
+			if f, err = os.Open(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if f != nil {
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+			// After a rotation the open file holds the finished segment; move on to the new one
+			cur, err1 := f.Stat()
+			next, err2 := os.Stat(path)
+			if err1 == nil && err2 == nil && !os.SameFile(cur, next) {
+				f.Close()
+				f = nil
+				continue
+			}
+		}
+
+		data, err := os.ReadFile(base + ".json")
+		if err != nil {
+			return err
+		}
+		var m logMeta
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+		if m.status() != "running" {
+			if f != nil {
+				_, err := io.Copy(w, f)
+				return err
+			}
+			return nil
+		}
+		time.Sleep(logPollInterval)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunLogRotates(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", filepath.Join(t.TempDir(), "state"))
+	l, err := startRunLog(&LogConfig{Enabled: true}, &workspace{Root: "/code/api", Slug: "acme/api"}, "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.maxSize = 32
+
+	for _, line := range []string{"first segment\n", "second segment\n", "third segment\n", "fourth\n"} {
+		if n, err := l.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write(%q) = %d, %v", line, n, err)
+		}
+	}
+	// At most two segments: the older one is replaced by each rotation
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	if got, want := read(l.path()+".1"), "first segment\nsecond segment\n"; got != want {
+		t.Errorf("%s.log.1 = %q, want %q", l.meta.ID, got, want)
+	}
+	if got, want := read(l.path()), "third segment\nfourth\n"; got != want {
+		t.Errorf("%s.log = %q, want %q", l.meta.ID, got, want)
+	}
+
+	l.finish(&exitError{code: 3, err: errors.New("failed")})
+	var m logMeta
+	if err := json.Unmarshal([]byte(read(filepath.Join(l.dir, l.meta.ID+".json"))), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Repo != "acme/api" || m.ExitCode != 3 || m.FinishedAt.IsZero() || m.status() != "exit 3" {
+		t.Errorf("meta = %+v, want a finished run with exit 3", m)
+	}
+	// Writes after the run finished are dropped
+	if n, err := l.Write([]byte("late\n")); err != nil || n != 5 {
+		t.Errorf("Write after finish = %d, %v", n, err)
+	}
+}
+
+func TestRunLogStopsWhenRotationFails(t *testing.T) {
+	dir := t.TempDir()
+	l := &runLog{dir: dir, meta: logMeta{ID: "run"}, maxSize: 4}
+	var err error
+	if l.f, err = os.Create(l.path()); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the rotated segment makes the rename fail
+	if err := os.MkdirAll(filepath.Join(l.path()+".1", "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []string{"abc", "defgh", "ijk"} {
+		if n, err := l.Write([]byte(s)); err != nil || n != len(s) {
+			t.Errorf("Write(%q) = %d, %v; want the output to go on", s, n, err)
+		}
+	}
+	if l.f != nil {
+		t.Error("log left open after a failed rotation")
+	}
+}
+
+func TestPruneLogs(t *testing.T) {
+	dir := t.TempDir()
+	// A pid that has exited, for a run that was interrupted
+	cmd := exec.Command("go", "version")
+	if err := cmd.Run(); err != nil {
+		t.Skip("can't start a process:", err)
+	}
+	gone := cmd.ProcessState.Pid()
+
+	base := time.Now().Add(-time.Hour)
+	metas := []logMeta{
+		{ID: "a", Pid: gone, StartedAt: base, FinishedAt: base.Add(time.Second)},
+		{ID: "b", Pid: os.Getpid(), StartedAt: base.Add(1 * time.Minute)}, // Still running
+		{ID: "c", Pid: gone, StartedAt: base.Add(2 * time.Minute)},        // Interrupted
+		{ID: "d", Pid: gone, StartedAt: base.Add(3 * time.Minute), FinishedAt: base.Add(4 * time.Minute)},
+		{ID: "e", Pid: gone, StartedAt: base.Add(5 * time.Minute), FinishedAt: base.Add(6 * time.Minute)},
+	}
+	for _, m := range metas {
+		l := &runLog{dir: dir, meta: m}
+		if err := l.writeMeta(); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{l.path(), l.path() + ".1"} {
+			if err := os.WriteFile(p, []byte("output\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := pruneLogs(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	left, err := loadLogMetas(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range left {
+		ids = append(ids, m.ID)
+	}
+	// The running log is kept even though it's among the oldest
+	if want := []string{"b", "d", "e"}; !slices.Equal(ids, want) {
+		t.Errorf("kept %q, want %q", ids, want)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, f := range files {
+		if name := filepath.Base(f); strings.HasPrefix(name, "a.") || strings.HasPrefix(name, "c.") {
+			t.Errorf("%s was not removed", name)
+		}
+	}
+}
@@ -2,19 +2,26 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"os/user"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time" // NEW: Required for cmdSetup
 
 	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
 )
 
 const Version = "0.0.3"
@@ -55,12 +62,37 @@ var builtinAliases = []BuiltinAlias{
 			return cmdCustom(cfg, true)
 		},
 	},
+	{
+		Name: "run", Desc: "Run a custom alias with its env and shell settings", Template: "",
+		// Used by the shell aliases that cmdInit emits for aliases with exec options
+		Handler: func(cfg *Config, args []string) error {
+			return cmdRunAlias(cfg, args)
+		},
+	},
 
 	// --- Project Navigation ---
 	{
-		Name: "pd", Desc: "Select a project directory", Template: `cd "$({{BIN}} pd)"`,
-		Handler: func(cfg *Config, _ []string) error {
-			return cmdProjectSelectDir(cfg)
+		Name: "pd", Desc: "Select a project directory. Usage: :pd [name] [--plain] [--tag x | @x]", Template: `cd "$({{BIN}} pd)"`,
+		Handler: func(cfg *Config, args []string) error {
+			return cmdProjectSelectDir(cfg, args)
+		},
+	},
+	{
+		Name: "pclone", Desc: "Clone a repo into project_dirs and cd into it. Usage: :pclone owner/repo", Template: `cd "$({{BIN}} pclone)"`,
+		Handler: func(cfg *Config, args []string) error {
+			return cmdProjectClone(cfg, args)
+		},
+	},
+	{
+		Name: "pnew", Desc: "Create a project from a template and cd into it. Usage: :pnew template name", Template: `cd "$({{BIN}} pnew)"`,
+		Handler: func(cfg *Config, args []string) error {
+			return cmdProjectNew(cfg, args)
+		},
+	},
+	{
+		Name: "projects", Desc: "List projects. Usage: :projects [--tag x | @x] [--json]", Template: "{{BIN}} projects",
+		Handler: func(cfg *Config, args []string) error {
+			return cmdProjects(cfg, args)
 		},
 	},
 	{
@@ -76,23 +108,55 @@ var builtinAliases = []BuiltinAlias{
 		},
 	},
 	{
-		Name: "pa", Desc: "Run actions for project", Template: "{{BIN}} pa",
+		Name: "pa", Desc: "Run actions for project. Usage: :pa [-m] [-w] [--bg] [--yes] [--all] [--all-packages] [name|group/name|key|!!|--history] [-- args]", Template: "{{BIN}} pa",
+		Handler: func(cfg *Config, args []string) error {
+			return cmdProjectActions(cfg, args)
+		},
+	},
+	{
+		Name: "history", Desc: "List past :pa runs. Usage: :history [--json]", Template: "{{BIN}} history",
+		Handler: func(cfg *Config, args []string) error {
+			return cmdHistory(cfg, args)
+		},
+	},
+	{
+		Name: "logs", Desc: "List logged :pa runs or show one. Usage: :logs [id [--follow]]", Template: "{{BIN}} logs",
+		Handler: func(cfg *Config, args []string) error {
+			return cmdLogs(cfg, args)
+		},
+	},
+
+	{
+		Name: "jobs", Desc: "List background :pa jobs, or stop or follow one. Usage: :jobs [stop|attach <id>]", Template: "{{BIN}} jobs",
+		Handler: func(cfg *Config, args []string) error {
+			return cmdJobs(cfg, args)
+		},
+	},
+	{
+		Name: "job", Desc: "Run a background :pa job", Template: "",
+		// Started detached by :pa --bg, with the job on stdin
 		Handler: func(cfg *Config, _ []string) error {
-			return cmdProjectActions(cfg)
+			return cmdRunJob(cfg)
+		},
+	},
+	{
+		Name: "dps", Desc: "Open a shell in or tail a running container. Usage: :dps [exec|logs]", Template: "{{BIN}} dps",
+		Handler: func(_ *Config, args []string) error {
+			return cmdDockerPS(args)
 		},
 	},
 
 	// --- Git Helpers (Subcommands) ---
 	{
 		Name: "gb", Desc: "Select a git branch", Template: "{{BIN}} gb",
-		Handler: func(_ *Config, _ []string) error {
-			return cmdGitSelectBranch()
+		Handler: func(cfg *Config, _ []string) error {
+			return cmdGitSelectBranch(cfg)
 		},
 	},
 	{
 		Name: "gnb", Desc: "Create a new git branch with <username>/ prefix. Usage: :gnb branch-name", Template: "{{BIN}} gnb",
-		Handler: func(_ *Config, args []string) error {
-			return cmdGitNewBranch(args)
+		Handler: func(cfg *Config, args []string) error {
+			return cmdGitNewBranch(cfg, args)
 		},
 	},
 	{
@@ -122,10 +186,20 @@ var builtinAliases = []BuiltinAlias{
 			return cmdOpenPullRequests()
 		},
 	},
+	{
+		Name: "main", Desc: "Switch to main branch", Template: "{{BIN}} main",
+		Handler: func(cfg *Config, _ []string) error {
+			return gitCheckout(cfg, "main", "main")
+		},
+	},
+	{
+		Name: "master", Desc: "Switch to master branch", Template: "{{BIN}} master",
+		Handler: func(cfg *Config, _ []string) error {
+			return gitCheckout(cfg, "master", "master")
+		},
+	},
 
 	// --- Pure Shell Aliases (No Go handler needed) ---
-	{Name: "main", Desc: "Switch to main branch", Template: "git checkout main"},
-	{Name: "master", Desc: "Switch to master branch", Template: "git checkout master"},
 	{Name: "gs", Desc: "git status", Template: "git status"},
 	{Name: "ll", Desc: "git pull", Template: "git pull"},
 	{Name: "gaa", Desc: "git add .", Template: "git add ."},
@@ -152,6 +226,16 @@ func GetMaxNameLength[T Nameable](aliases []T) int {
 	return maxNameLen
 }
 
+// shellFunctionAliases are built-ins that cd into a path printed by colonsh. They
+// need argument forwarding, so cmdInit emits shell functions for them instead of aliases.
+// Their stdout is reserved for that path, so they print status to stderr.
+var shellFunctionAliases = map[string]bool{
+	"pd":     true,
+	"cd":     true,
+	"pclone": true,
+	"pnew":   true,
+}
+
 // commandHandlers maps a command name string to its execution function.
 var commandHandlers = map[string]CommandFunc{}
 
@@ -167,12 +251,13 @@ func init() {
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, "colonsh:", err)
-		os.Exit(1)
+		// Exit with the failing child's code where there is one
+		os.Exit(max(exitCode(err), 1))
 	}
 }
 
 func run() error {
-	args := os.Args[1:]
+	args := parseDryRun(os.Args[1:])
 
 	if len(args) > 0 {
 		// Handle other version flags
@@ -275,6 +360,52 @@ func cmdCustom(cfg *Config, showHeader bool) error {
 	return nil
 }
 
+func cmdRunAlias(cfg *Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: colonsh run <alias> [args...]")
+	}
+
+	var alias *Alias
+	for i := range cfg.Aliases {
+		if cfg.Aliases[i].Name == args[0] {
+			alias = &cfg.Aliases[i]
+			break
+		}
+	}
+	if alias == nil {
+		return fmt.Errorf("no custom alias named %q", args[0])
+	}
+
+	// env_file paths are relative to the git root, or the current directory outside a repo
+	baseDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if inGitRepo() {
+		if root, err := gitRoot(); err == nil {
+			baseDir = root
+		}
+	}
+
+	var sc shellCommand
+	if err := applyExecOptions(&sc, alias.ExecOptions, baseDir); err != nil {
+		return fmt.Errorf("alias %q: %w", alias.Name, err)
+	}
+	if sc.Shell == "none" {
+		sc.Argv = alias.Argv
+		if len(sc.Argv) == 0 {
+			sc.Argv = strings.Fields(alias.Cmd)
+		}
+		sc.Argv = append(sc.Argv, args[1:]...)
+	} else {
+		sc.Cmd = alias.Cmd
+		for _, a := range args[1:] {
+			sc.Cmd += " " + shellQuoteArg(a)
+		}
+	}
+	return sc.Run(context.Background())
+}
+
 func cmdVersion() error {
 	// Now prints the globally defined Version constant
 	fmt.Println("colonsh version:", Version)
@@ -312,7 +443,7 @@ Set-Alias -Name ':help' -Value colonsh
 		// NOTE: Complex aliases like :pd='cd "$(colonsh pd)"' require PowerShell functions
 		// instead of simple Set-Alias, which is too complex for this init output.
 		for _, ba := range builtinAliases {
-			if ba.Template == "" || ba.Name == "help" || ba.Name == "pd" || ba.Name == "cd" {
+			if ba.Template == "" || ba.Name == "help" || shellFunctionAliases[ba.Name] {
 				continue
 			}
 			cmd := strings.ReplaceAll(ba.Template, "{{BIN}}", "$COLONSH_BIN")
@@ -337,7 +468,7 @@ alias :help='$COLONSH_BIN'
 		// Dynamically generate aliases from builtinAliases
 		for _, ba := range builtinAliases {
 			// Skip commands that don't have a template (like 'init', 'setup')
-			if ba.Template == "" || ba.Name == "help" || ba.Name == "pd" || ba.Name == "cd" {
+			if ba.Template == "" || ba.Name == "help" || shellFunctionAliases[ba.Name] {
 				continue
 			}
 
@@ -354,6 +485,12 @@ alias :help='$COLONSH_BIN'
 
 		__colonsh_pd() { builtin cd "$("$COLONSH_BIN" pd "$@")"; }
 		alias :pd='__colonsh_pd'
+
+		__colonsh_pclone() { local dir; dir="$("$COLONSH_BIN" pclone "$@")" && builtin cd "$dir"; }
+		alias :pclone='__colonsh_pclone'
+
+		__colonsh_pnew() { local dir; dir="$("$COLONSH_BIN" pnew "$@")" && builtin cd "$dir"; }
+		alias :pnew='__colonsh_pnew'
 		`)
 	}
 
@@ -364,10 +501,15 @@ alias :help='$COLONSH_BIN'
 			if a.Name == "" || a.Cmd == "" {
 				continue
 			}
+			cmd := a.Cmd
+			if a.hasEffect() {
+				// env/shell settings can't be expressed in a plain alias; let colonsh run it
+				cmd = fmt.Sprintf("$COLONSH_BIN run %s", shellQuoteArg(a.Name))
+			}
 			if shellArg == "powershell" {
-				buf.WriteString(fmt.Sprintf("Set-Alias -Name ':%s' -Value '%s'\n", a.Name, a.Cmd))
+				buf.WriteString(fmt.Sprintf("Set-Alias -Name ':%s' -Value '%s'\n", a.Name, strings.ReplaceAll(cmd, "$COLONSH_BIN", exe)))
 			} else {
-				buf.WriteString(fmt.Sprintf("alias :%s='%s'\n", a.Name, shellQuoteSingle(a.Cmd)))
+				buf.WriteString(fmt.Sprintf("alias :%s='%s'\n", a.Name, shellQuoteSingle(cmd)))
 			}
 		}
 	}
@@ -436,6 +578,10 @@ fi
 `, time.Now().Format("2006-01-02"), targetShell)
 
 	// 4. Append the block to the profile file
+	if dryRun {
+		printDryRunWrite("shell profile", expandedPath)
+		return nil
+	}
 	f, err := os.OpenFile(expandedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
 	if err != nil {
 		return fmt.Errorf("failed to open %s for writing: %w", expandedPath, err)
@@ -468,46 +614,76 @@ func cmdOpenConfig() error {
 	return openPath(configPath)
 }
 
-func cmdProjectSelectDir(cfg *Config) error {
-	var projects []string
-
-	for _, pd := range cfg.ProjectDirs {
-		root, err := expandTilde(pd.Path)
-		if err != nil {
-			return err
-		}
-		entries, err := os.ReadDir(root)
-		if err != nil {
-			continue
+func cmdProjectSelectDir(cfg *Config, args []string) error {
+	tags, args, err := parseTagArgs(args)
+	if err != nil {
+		return err
+	}
+	plain := false
+	jumpTo := ""
+	for _, a := range args {
+		switch {
+		case a == "--plain":
+			plain = true
+		case !strings.HasPrefix(a, "-") && jumpTo == "":
+			jumpTo = a
 		}
+	}
 
-		exclude := make(map[string]struct{}, len(pd.Exclude))
-		for _, ex := range pd.Exclude {
-			exclude[ex] = struct{}{}
-		}
+	projects, err := discoverProjects(cfg)
+	if err != nil {
+		return err
+	}
 
-		for _, e := range entries {
-			if !e.IsDir() {
-				continue
+	// :pd <name> jumps straight to a bookmark or project without the picker
+	if jumpTo != "" {
+		if b := findBookmarkByName(cfg, jumpTo); b != nil {
+			path, err := expandTilde(b.Path)
+			if err != nil {
+				return err
 			}
-			name := e.Name()
-			if _, skip := exclude[name]; skip {
-				continue
+			fmt.Println(path)
+			return nil
+		}
+		for _, p := range projects {
+			if strings.EqualFold(p.Name, jumpTo) {
+				fmt.Println(p.Path)
+				return nil
 			}
-			projects = append(projects, filepath.Join(root, name))
 		}
+		return fmt.Errorf("no bookmark or project named %q", jumpTo)
 	}
 
+	if len(tags) > 0 {
+		applyRepoTags(cfg, projects)
+		projects = filterProjectsByTags(projects, tags)
+		if len(projects) == 0 {
+			return fmt.Errorf("no projects tagged %s", strings.Join(tags, ", "))
+		}
+	}
 	if len(projects) == 0 {
-		return errors.New("no projects found from project_dirs")
+		return errors.New("no projects found from project_dirs or bookmarks")
 	}
 
-	var selected string
 	opts := []huh.Option[string]{}
-	for _, p := range projects {
-		opts = append(opts, huh.NewOption(p, p))
+	if plain {
+		for _, p := range projects {
+			opts = append(opts, huh.NewOption(p.Path, p.Path))
+		}
+	} else {
+		statuses := collectProjectStatuses(projects)
+
+		nameWidth, branchWidth := 0, 0
+		for i, p := range projects {
+			nameWidth = max(nameWidth, lipgloss.Width(p.Name))
+			branchWidth = max(branchWidth, lipgloss.Width(statuses[i].Branch))
+		}
+		for i, p := range projects {
+			opts = append(opts, huh.NewOption(projectRowLabel(p, statuses[i], nameWidth, branchWidth), p.Path))
+		}
 	}
 
+	var selected string
 	if err := huh.NewSelect[string]().
 		Title("Select a project directory").
 		Options(opts...).
@@ -525,97 +701,64 @@ func cmdProjectSelectDir(cfg *Config) error {
 	return nil
 }
 
-func cmdProjectOpen(cfg *Config) error {
-	var baseDir string
-
-	// 1. Enforce requirement: Must be inside a Git repository.
-	if !inGitRepo() {
-		return errors.New("command 'po' currently expects to be run inside a git repository")
-	}
-
-	// 2. Get the root directory of the repository.
-	var err error
-	baseDir, err = gitRoot()
+func cmdProjects(cfg *Config, args []string) error {
+	tags, args, err := parseTagArgs(args)
 	if err != nil {
-		return fmt.Errorf("failed to get git root: %w", err)
-	}
-
-	// 3. Find the specific repository config using the new lookup function.
-	// This function handles getting the slug and finding the matching config entry.
-	repo := findCurrentRepo(cfg)
-
-	// 4. Determine the open command, prioritizing repo-specific setting.
-
-	// Start with global default (from config or hardcoded)
-	openCmd := cfg.OpenCmd
-	if openCmd == "" {
-		openCmd = "code ."
-	}
-
-	// Override with repo-specific setting if a matching GitRepo was found
-	if repo != nil && repo.OpenCmd != "" {
-		openCmd = repo.OpenCmd
+		return err
 	}
-
-	// 5. Execute the command in the root directory.
-	fmt.Printf("Opening project at %s with: %s\n", baseDir, openCmd)
-	return runShellCommand(openCmd, baseDir)
-}
-
-func cmdProjectActions(cfg *Config) error {
-	if !inGitRepo() {
-		return errors.New("not inside a git repository")
+	asJSON := false
+	for _, a := range args {
+		if a == "--json" {
+			asJSON = true
+		}
 	}
 
-	root, err := gitRoot()
+	projects, err := discoverProjects(cfg)
 	if err != nil {
 		return err
 	}
+	applyRepoTags(cfg, projects)
+	projects = filterProjectsByTags(projects, tags)
 
-	repo := findCurrentRepo(cfg)
-	if repo == nil || len(repo.Actions) == 0 {
-		return errors.New("no actions found for this repository in colonsh.json")
-	}
-
-	opts := []huh.Option[string]{}
-	for _, a := range repo.Actions {
-		opts = append(opts, huh.NewOption(a.Name, a.Name))
+	if asJSON {
+		if projects == nil {
+			projects = []Project{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(projects)
 	}
 
-	var selectedName string
-	if err := huh.NewSelect[string]().
-		Title("Select an action").
-		Options(opts...).
-		Value(&selectedName).
-		Run(); err != nil {
-		return err
-	}
-	if selectedName == "" {
-		fmt.Println("No action selected.")
-		return nil
+	nameWidth := 0
+	for _, p := range projects {
+		nameWidth = max(nameWidth, lipgloss.Width(p.Name))
 	}
-
-	var action *RepoAction
-	for i := range repo.Actions {
-		if repo.Actions[i].Name == selectedName {
-			action = &repo.Actions[i]
-			break
+	for _, p := range projects {
+		line := padRight(p.Name, nameWidth) + "  " + p.Path
+		if len(p.Tags) > 0 {
+			line += "  @" + strings.Join(p.Tags, " @")
 		}
+		fmt.Println(line)
 	}
-	if action == nil {
-		return errors.New("selected action not found")
-	}
+	return nil
+}
 
-	runDir := root
-	if action.Dir != "" && action.Dir != "." {
-		runDir = filepath.Join(root, action.Dir)
+func cmdProjectOpen(cfg *Config) error {
+	// 1. Resolve the git repository or bookmarked directory we're in.
+	ws, err := currentWorkspace(cfg)
+	if err != nil {
+		return fmt.Errorf("command 'po': %w", err)
 	}
 
-	fmt.Printf("Executing action %q in %s: %s\n", action.Name, runDir, action.Cmd)
-	return runShellCommand(action.Cmd, runDir)
+	// 2. Determine the open command: bookmark, then repo, then global setting.
+	openCmd := ws.openCmd(cfg)
+
+	// 3. Execute the command in the root directory.
+	fmt.Printf("Opening project at %s with: %s\n", ws.Root, openCmd)
+	return runShellCommand(openCmd, ws.Root)
 }
 
-func cmdGitSelectBranch() error {
+func cmdGitSelectBranch(cfg *Config) error {
 	branches, err := gitBranchesRaw()
 	if err != nil {
 		return err
@@ -643,14 +786,10 @@ func cmdGitSelectBranch() error {
 	}
 
 	fmt.Println("Switching to branch:", selected)
-	cmd := exec.Command("git", "checkout", selected)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return gitCheckout(cfg, selected, selected)
 }
 
-func cmdGitNewBranch(args []string) error {
+func cmdGitNewBranch(cfg *Config, args []string) error {
 	if len(args) == 0 {
 		return errors.New("usage: colonsh gnb <branch-name>")
 	}
@@ -664,11 +803,7 @@ func cmdGitNewBranch(args []string) error {
 	full := fmt.Sprintf("%s/%s", username, branchName)
 
 	fmt.Println("Creating and switching to branch:", full)
-	cmd := exec.Command("git", "checkout", "-b", full)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return gitCheckout(cfg, full, "-b", full)
 }
 
 func cmdGitDeleteBranch() error {
@@ -733,7 +868,7 @@ func cmdGitDeleteBranch() error {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Stdin = os.Stdin
-		_ = cmd.Run() // ignore individual failures, just print output
+		_ = runCmd(cmd) // ignore individual failures, just print output
 	}
 
 	return nil
@@ -748,7 +883,7 @@ func cmdGitCommit(args []string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
 func cmdGitCommitAmendWithMessage(args []string) error {
@@ -760,7 +895,7 @@ func cmdGitCommitAmendWithMessage(args []string) error {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return runCmd(cmd)
 }
 
 func cmdOpenPullRequests() error {
@@ -857,6 +992,14 @@ func shellQuoteSingle(s string) string {
 	return strings.ReplaceAll(s, `'`, `'\''`)
 }
 
+// shellQuoteArg quotes s for safe use as a single shell word, leaving simple words as-is.
+func shellQuoteArg(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,+@%") == "" {
+		return s
+	}
+	return "'" + shellQuoteSingle(s) + "'"
+}
+
 func detectShell() string {
 	shellPath := os.Getenv("SHELL")
 	if shellPath != "" {
@@ -891,12 +1034,115 @@ func expandTilde(path string) (string, error) {
 }
 
 func runShellCommand(cmdStr string, dir string) error {
-	if cmdStr == "" {
-		return errors.New("empty command")
+	return shellCommand{Cmd: cmdStr, Dir: dir}.Run(context.Background())
+}
+
+// errInterrupted marks errors from commands that colonsh was asked to stop.
+var errInterrupted = errors.New("interrupted")
+
+// shellCommand describes a command string run through the user's shell.
+// Nil writers and reader default to the process's own stdio.
+type shellCommand struct {
+	Cmd          string
+	Argv         []string // Program and arguments, used instead of Cmd when Shell is "none"
+	Dir          string
+	Env          []string // Extra KEY=VALUE entries on top of the inherited environment
+	Shell        string   // Overrides $SHELL: bash, sh, zsh, pwsh, or none for direct exec
+	NoLogin      bool     // Run the shell with -c instead of -lc
+	Check        bool     // Runs in dry-run mode too; for commands that only check something
+	NoForeground bool     // Keep the terminal's foreground so Ctrl-C reaches colonsh; stdin defaults to empty
+	Stdout       io.Writer
+	Stderr       io.Writer
+	Stdin        io.Reader
+}
+
+// Run executes the command, killing it if ctx is cancelled.
+func (sc shellCommand) Run(ctx context.Context) error {
+	argv, err := sc.argv()
+	if err != nil {
+		return err
+	}
+	if dryRun && !sc.Check {
+		printDryRun(argv, sc.Dir, sc.Env)
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+
+	if sc.Dir != "" {
+		cmd.Dir = sc.Dir
+	}
+	if len(sc.Env) > 0 {
+		cmd.Env = append(os.Environ(), sc.Env...)
+	}
+
+	cmd.Stdout = cmp.Or[io.Writer](sc.Stdout, os.Stdout)
+	cmd.Stderr = cmp.Or[io.Writer](sc.Stderr, os.Stderr)
+	if sc.Stdin != nil || !sc.NoForeground {
+		cmd.Stdin = cmp.Or[io.Reader](sc.Stdin, os.Stdin)
+	}
+	// Don't wait forever on output pipes held open by orphaned grandchildren
+	cmd.WaitDelay = 5 * time.Second
+
+	restore := configureProcessGroup(cmd, !sc.NoForeground)
+	if err := cmd.Start(); err != nil {
+		restore()
+		return err
+	}
+
+	// Forward SIGINT/SIGTERM sent to colonsh to the child's whole process group
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	var forwarded atomic.Bool
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				forwarded.Store(true)
+				signalProcessGroup(cmd.Process, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	signal.Stop(sigs)
+	close(done)
+	restore()
+
+	if err != nil && forwarded.Load() {
+		return fmt.Errorf("%w: %w", errInterrupted, err)
+	}
+	return err
+}
+
+// argv returns the program and arguments used to run the command.
+func (sc shellCommand) argv() ([]string, error) {
+	if sc.Shell == "none" {
+		if len(sc.Argv) == 0 {
+			return nil, errors.New("shell \"none\" requires argv")
+		}
+		return sc.Argv, nil
+	}
+	if sc.Cmd == "" {
+		return nil, errors.New("empty command")
+	}
+
+	if sc.Shell == "pwsh" || sc.Shell == "powershell" {
+		args := []string{sc.Shell, "-NoLogo"}
+		if sc.NoLogin {
+			args = append(args, "-NoProfile")
+		}
+		return append(args, "-Command", sc.Cmd), nil
 	}
 
 	// Check the user's SHELL environment variable
-	shell := os.Getenv("SHELL")
+	shell := sc.Shell
+	if shell == "" {
+		shell = os.Getenv("SHELL")
+	}
 	if shell == "" {
 		// Fallback if SHELL is not set, or on Windows
 		if runtime.GOOS == "windows" {
@@ -908,16 +1154,11 @@ func runShellCommand(cmdStr string, dir string) error {
 
 	// The flag to execute the command in the shell might need adjustment:
 	// bash/zsh often use -c, but -lc is safer for initialization files.
-	cmd := exec.Command(shell, "-lc", cmdStr)
-
-	if dir != "" {
-		cmd.Dir = dir
+	flag := "-lc"
+	if sc.NoLogin {
+		flag = "-c"
 	}
-
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-	return cmd.Run()
+	return []string{shell, flag, sc.Cmd}, nil
 }
 
 func inGitRepo() bool {
@@ -956,7 +1197,11 @@ func gitRepoSlug() (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return slugFromRemoteURL(rawURL)
+}
 
+// slugFromRemoteURL normalizes an SSH or HTTP(S) remote URL into a "user/repo" slug.
+func slugFromRemoteURL(rawURL string) (string, error) {
 	// 2. Normalize the URL (Replaces normalizeGitURL)
 	s := strings.TrimSpace(rawURL)
 
@@ -984,26 +1229,6 @@ func gitRepoSlug() (string, error) {
 	return parts[1], nil
 }
 
-// findCurrentRepo executes the Git command to find the current repository slug
-// and then performs the lookup against the provided config.
-func findCurrentRepo(cfg *Config) *GitRepo {
-	// Note: This function now executes external commands (Git) and should handle errors internally
-
-	repoSlug, err := gitRepoSlug() // This function contains the Git execution logic
-	if err != nil {
-		// If we can't get the slug (e.g., no remote), return nil
-		return nil
-	}
-
-	// Perform the lookup against the config list
-	for i := range cfg.GitRepos {
-		if cfg.GitRepos[i].Slug == repoSlug {
-			return &cfg.GitRepos[i]
-		}
-	}
-	return nil
-}
-
 func gitBranchesRaw() ([]string, error) {
 	cmd := exec.Command("git", "branch", "--format=%(refname:short)")
 	var out bytes.Buffer
@@ -1023,6 +1248,18 @@ func gitBranchesRaw() ([]string, error) {
 	return branches, nil
 }
 
+// currentBranch returns the branch checked out in dir; empty outside a repository
+// or on a detached HEAD.
+func currentBranch(dir string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), projectStatusTimeout)
+	defer cancel()
+	out, err := gitOutputContext(ctx, dir, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
 func collectDirsRecursive(root string, maxDepth int) ([]string, error) {
 	var results []string
 
@@ -1086,7 +1323,7 @@ func openPath(path string) error {
 	if cmd != nil {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		return cmd.Run()
+		return runCmd(cmd)
 	}
 
 	return fmt.Errorf("unsupported operating system or failed to open path: %s", path)
@@ -1111,7 +1348,7 @@ func runLinuxBrowserCommand(url string) error {
 		}
 
 		// Run the command
-		if err := cmd.Run(); err == nil {
+		if err := runCmd(cmd); err == nil {
 			return nil // Success!
 		}
 	}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultNotifyMinDuration keeps quick actions from notifying.
+	defaultNotifyMinDuration = 10 * time.Second
+	// notifyCommandTimeout bounds how long a notifier command may take.
+	notifyCommandTimeout = 10 * time.Second
+)
+
+// notifySettings combines the global notify settings with those of the actions
+// that were asked for; later actions override earlier ones.
+func notifySettings(global *NotifyConfig, actions []*RepoAction) NotifyConfig {
+	layers := []*NotifyConfig{global}
+	for _, a := range actions {
+		layers = append(layers, a.Notify)
+	}
+
+	var nc NotifyConfig
+	for _, n := range layers {
+		if n == nil {
+			continue
+		}
+		enabled := n.Enabled == nil || *n.Enabled
+		nc.Enabled = &enabled
+		nc.MinDuration = cmp.Or(n.MinDuration, nc.MinDuration)
+		nc.Method = cmp.Or(n.Method, nc.Method)
+		nc.Command = cmp.Or(n.Command, nc.Command)
+	}
+	return nc
+}
+
+// sendNotification tells the user a run finished, if notifications are on and it
+// took at least the minimum duration. Runs the user interrupted don't notify.
+func (r *actionRun) sendNotification(steps []*RepoAction, took time.Duration, runErr error) {
+	// Dependencies don't decide how the action that was asked for notifies
+	asked := steps
+	if r.target != nil {
+		asked = []*RepoAction{r.target}
+	}
+	nc := notifySettings(r.notify, asked)
+	if nc.Enabled == nil || !*nc.Enabled || wasInterrupted(runErr) {
+		return
+	}
+	minDuration := defaultNotifyMinDuration
+	if nc.MinDuration != "" {
+		// Validated when the config was loaded
+		minDuration, _ = parseOptionalDuration(nc.MinDuration)
+	}
+	if took < minDuration {
+		return
+	}
+
+	name := r.name(steps)
+	took = took.Round(time.Second)
+	message := fmt.Sprintf("✓ %s finished in %s", name, took)
+	if runErr != nil {
+		message = fmt.Sprintf("✗ %s failed (exit %d) after %s", name, exitCode(runErr), took)
+	}
+	title := "colonsh: " + cmp.Or(r.ws.Slug, r.ws.Root)
+
+	if nc.Method == notifyCommand {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyCommandTimeout)
+		defer cancel()
+		sc := shellCommand{
+			Cmd:     nc.Command,
+			Dir:     r.ws.Root,
+			NoLogin: true,
+			Env: append(workspaceEnv(r.ws),
+				"COLONSH_NOTIFY_TITLE="+title,
+				"COLONSH_NOTIFY_MESSAGE="+message,
+				"COLONSH_ACTION="+name,
+				"COLONSH_EXIT_CODE="+strconv.Itoa(exitCode(runErr)),
+				"COLONSH_DURATION="+strconv.Itoa(int(took.Seconds())),
+			),
+			Stdout: io.Discard,
+			Stdin:  strings.NewReader(""),
+		}
+		if err := sc.Run(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notify command failed: %v\n", err)
+		}
+		return
+	}
+
+	seq := "\x1b]9;" + title + ": " + message + "\x07"
+	if nc.Method == notifyOSC777 {
+		seq = "\x1b]777;notify;" + title + ";" + message + "\x07"
+	}
+	if os.Getenv("TMUX") != "" {
+		// tmux only forwards escape sequences wrapped in a passthrough
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	writeToTerminal(seq)
+}
+
+// writeToTerminal writes an escape sequence to the controlling terminal, never to
+// redirected output. Without a terminal, e.g. in a background job, it does nothing.
+func writeToTerminal(seq string) {
+	if f, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer f.Close()
+		io.WriteString(f, seq)
+		return
+	}
+	// Windows has no /dev/tty; use stderr when it is the console
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		io.WriteString(os.Stderr, seq)
+	}
+}
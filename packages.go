@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+// packagePlaceholder is replaced by the package path in the commands of actions
+// with packages.
+const packagePlaceholder = "{{package}}"
+
+// matchPackage reports whether dir, relative to the root, matches one of globs.
+func matchPackage(globs []string, dir string) bool {
+	return slices.ContainsFunc(globs, func(g string) bool {
+		ok, _ := path.Match(path.Clean(g), dir)
+		return ok
+	})
+}
+
+// currentPackage returns the package of globs that the current directory is in,
+// the deepest one if packages are nested, or "" outside all of them.
+func (w *workspace) currentPackage(globs []string) string {
+	if len(globs) == 0 {
+		return ""
+	}
+	for dir := w.Dir; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if matchPackage(globs, dir) {
+			return dir
+		}
+	}
+	return ""
+}
+
+// findPackages lists the directories under root that match globs, relative to root.
+func findPackages(root string, globs []string) []string {
+	var pkgs []string
+	for _, g := range globs {
+		matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(path.Clean(g))))
+		for _, m := range matches {
+			if info, err := os.Stat(m); err != nil || !info.IsDir() {
+				continue
+			}
+			if rel, err := filepath.Rel(root, m); err == nil {
+				pkgs = append(pkgs, filepath.ToSlash(rel))
+			}
+		}
+	}
+	slices.Sort(pkgs)
+	return slices.Compact(pkgs)
+}
+
+// prioritizePackage moves the actions that apply to the package the current
+// directory is in to a section of their own at the top of the menu.
+func prioritizePackage(ws *workspace, actions []RepoAction, sections []actionSection) ([]RepoAction, []actionSection) {
+	var first, rest []RepoAction
+	var restSections []actionSection
+	title := ""
+	for s, sec := range sections {
+		end := len(actions)
+		if s+1 < len(sections) {
+			end = sections[s+1].Start
+		}
+		start := len(rest)
+		for _, a := range actions[sec.Start:end] {
+			if pkg := ws.currentPackage(a.Packages); pkg != "" {
+				if title == "" {
+					title = pkg
+				}
+				first = append(first, a)
+			} else {
+				rest = append(rest, a)
+			}
+		}
+		if len(rest) > start {
+			restSections = append(restSections, actionSection{Title: sec.Title, Start: start})
+		}
+	}
+	if len(first) == 0 {
+		return actions, sections
+	}
+
+	out := append(first, rest...)
+	outSections := []actionSection{{Title: title, Start: 0}}
+	for _, sec := range restSections {
+		outSections = append(outSections, actionSection{Title: sec.Title, Start: len(first) + sec.Start})
+	}
+	return out, outSections
+}
+
+// resolvePackages decides which package each step with packages runs in: the one
+// the current directory is in, else the one picked for an earlier step, else one
+// chosen from a picker. With --all-packages the target fans out instead.
+func (r *actionRun) resolvePackages(steps []*RepoAction) error {
+	r.packages = make(map[*RepoAction]string)
+	r.origin = make(map[*RepoAction]*RepoAction)
+	picked := ""
+	for _, step := range steps {
+		if len(step.Packages) == 0 || (step == r.target && r.args.AllPackages) {
+			continue
+		}
+		if pkg := r.ws.currentPackage(step.Packages); pkg != "" {
+			r.packages[step] = pkg
+			continue
+		}
+		if picked != "" && matchPackage(step.Packages, picked) {
+			r.packages[step] = picked
+			continue
+		}
+
+		pkgs := findPackages(r.ws.Root, step.Packages)
+		switch len(pkgs) {
+		case 0:
+			return fmt.Errorf("action %q: no directories match packages %s", step.Name, strings.Join(step.Packages, ", "))
+		case 1:
+			picked = pkgs[0]
+		default:
+			opts := make([]huh.Option[string], len(pkgs))
+			for i, p := range pkgs {
+				opts[i] = huh.NewOption(p, p)
+			}
+			if err := huh.NewSelect[string]().
+				Title(fmt.Sprintf("Select a package for %q", step.Name)).
+				Options(opts...).
+				Value(&picked).
+				Run(); err != nil {
+				return err
+			}
+		}
+		r.packages[step] = picked
+	}
+	return nil
+}
+
+// runPackages runs target once in every package matching its globs, in parallel.
+func (r *actionRun) runPackages(ctx context.Context, target *RepoAction) error {
+	pkgs := findPackages(r.ws.Root, target.Packages)
+	if len(pkgs) == 0 {
+		return fmt.Errorf("action %q: no directories match packages %s", target.Name, strings.Join(target.Packages, ", "))
+	}
+
+	// Each package gets a copy of the action, named after the package for the output prefixes
+	runs := make([]*RepoAction, len(pkgs))
+	for i, pkg := range pkgs {
+		a := *target
+		a.Name = pkg
+		runs[i] = &a
+		r.packages[&a] = pkg
+		r.origin[&a] = target
+	}
+	limit := target.MaxParallel
+	if r.args.MaxParallel > 0 {
+		limit = r.args.MaxParallel
+	}
+	return r.runParallel(ctx, runs, target.FailFast || r.args.FailFast, limit)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prefixColors are the ANSI colors cycled through for parallel output prefixes.
+var prefixColors = []string{"36", "33", "35", "32", "34", "31"}
+
+// parallelMembers resolves the Steps of a parallel action to command actions.
+func parallelMembers(actions []RepoAction, group *RepoAction) ([]*RepoAction, error) {
+	if len(group.Steps) == 0 {
+		return nil, &actionError{group, fmt.Errorf("parallel action %q has no steps", group.Name)}
+	}
+	var members []*RepoAction
+	for _, name := range group.Steps {
+		var found *RepoAction
+		for i := range actions {
+			if strings.EqualFold(actions[i].Name, name) {
+				found = &actions[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, &actionError{group, fmt.Errorf("parallel action %q has unknown step %q", group.Name, name)}
+		}
+		if found.Type == actionTypeParallel {
+			return nil, &actionError{group, fmt.Errorf("parallel action %q cannot contain parallel action %q", group.Name, name)}
+		}
+		members = append(members, found)
+	}
+	return members, nil
+}
+
+// runParallel runs the given actions concurrently with prefixed output. At most
+// limit run at once (0 means no limit). With failFast, the first failure cancels
+// the others.
+func (r *actionRun) runParallel(ctx context.Context, actions []*RepoAction, failFast bool, limit int) error {
+	if limit <= 0 || limit > len(actions) {
+		limit = len(actions)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	names := make([]string, len(actions))
+	width := 0
+	for i, a := range actions {
+		names[i] = a.Name
+		width = max(width, len(a.Name))
+	}
+	fmt.Printf("Running %d actions in parallel: %s\n", len(actions), strings.Join(names, ", "))
+
+	var outMu sync.Mutex
+	results := make([]stepResult, len(actions))
+	errs := make([]error, len(actions))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, a := range actions {
+		results[i].Name = a.Name
+	}
+
+	// Slots are taken in order, so with a limit the actions start in the order given
+	for i, a := range actions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, a *RepoAction) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			prefix := colorPrefix(fmt.Sprintf("%-*s │ ", width, a.Name), i)
+			stdout := &prefixWriter{mu: &outMu, out: os.Stdout, prefix: prefix}
+			stderr := &prefixWriter{mu: &outMu, out: os.Stderr, prefix: prefix}
+			writers := []*prefixWriter{stdout, stderr}
+			var out, errOut io.Writer = stdout, stderr
+			if r.log != nil {
+				// The log gets the same lines without the color codes
+				plain := fmt.Sprintf("%-*s │ ", width, a.Name)
+				logOut := &prefixWriter{mu: &outMu, out: r.log, prefix: plain}
+				logErr := &prefixWriter{mu: &outMu, out: r.log, prefix: plain}
+				writers = append(writers, logOut, logErr)
+				out, errOut = io.MultiWriter(stdout, logOut), io.MultiWriter(stderr, logErr)
+			}
+
+			start := time.Now()
+			sc, _, err := r.command(a)
+			if err == nil {
+				sc.Stdout, sc.Stderr, sc.Stdin = out, errOut, bytes.NewReader(nil)
+				err = runWithPolicy(ctx, a, sc, out)
+			} else {
+				fmt.Fprintln(errOut, err)
+			}
+			for _, w := range writers {
+				w.Flush()
+			}
+
+			results[i].Ran = true
+			results[i].Duration = time.Since(start)
+			results[i].ExitCode = exitCode(err)
+			if err != nil && ctx.Err() != nil {
+				// Killed because a sibling failed
+				results[i].Cancelled = true
+				return
+			}
+			errs[i] = err
+			if err != nil && failFast {
+				cancel()
+			}
+		}(i, a)
+	}
+	wg.Wait()
+
+	printPlanSummary(results)
+
+	failed := 0
+	var first error
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			if first == nil {
+				first = fmt.Errorf("action %q failed: %w", results[i].Name, err)
+			}
+		}
+	}
+	if failed > 1 {
+		return fmt.Errorf("%d of %d actions failed; first: %w", failed, len(actions), first)
+	}
+	return first
+}
+
+// colorPrefix wraps s in an ANSI color picked by index, unless NO_COLOR is set.
+func colorPrefix(s string, i int) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	return "\x1b[" + prefixColors[i%len(prefixColors)] + "m" + s + "\x1b[0m"
+}
+
+// prefixWriter writes complete lines to out with a prefix, buffering partial lines.
+// Writers sharing mu never interleave within a line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line, terminating it with a newline.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(line)
+}
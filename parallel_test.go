@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriterPartialLines(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &sync.Mutex{}, out: &out, prefix: "[api] "}
+
+	for _, s := range []string{"star", "ting\nready", "", "\nline 3\nline 4\nno newline"} {
+		if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got, want := out.String(), "[api] starting\n[api] ready\n[api] line 3\n[api] line 4\n"; got != want {
+		t.Errorf("before Flush = %q, want %q", got, want)
+	}
+	w.Flush()
+	w.Flush()
+	if got, want := out.String(), "[api] starting\n[api] ready\n[api] line 3\n[api] line 4\n[api] no newline\n"; got != want {
+		t.Errorf("after Flush = %q, want %q", got, want)
+	}
+}
+
+func TestPrefixWritersDontInterleave(t *testing.T) {
+	var out bytes.Buffer
+	mu := &sync.Mutex{}
+	const writers, lines = 8, 200
+
+	var wg sync.WaitGroup
+	for i := range writers {
+		w := &prefixWriter{mu: mu, out: &out, prefix: fmt.Sprintf("[%d] ", i)}
+		wg.Go(func() {
+			for n := range lines {
+				// Split each line across writes so partial lines are buffered
+				line := fmt.Sprintf("writer %d line %d\n", i, n)
+				w.Write([]byte(line[:5]))
+				w.Write([]byte(line[5:]))
+			}
+		})
+	}
+	wg.Wait()
+
+	got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(got) != writers*lines {
+		t.Fatalf("got %d lines, want %d", len(got), writers*lines)
+	}
+	next := make([]int, writers)
+	for _, line := range got {
+		var i, j, n int
+		if _, err := fmt.Sscanf(line, "[%d] writer %d line %d", &i, &j, &n); err != nil || i != j || n != next[i] {
+			t.Fatalf("line %q is mixed up or out of order", line)
+		}
+		next[i]++
+	}
+}
@@ -0,0 +1,82 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// killGracePeriod is how long a cancelled process group gets between SIGTERM and SIGKILL.
+const killGracePeriod = 3 * time.Second
+
+// configureProcessGroup runs cmd in its own process group so the whole tree can be
+// signalled and killed on cancellation. With foreground set, when cmd's stdin is our
+// controlling terminal and we own the foreground, the child's group is made the
+// foreground group so interactive programs (and Ctrl-C) keep working. The returned
+// func must be called after the process exits to take the terminal back.
+func configureProcessGroup(cmd *exec.Cmd, foreground bool) (restore func()) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pid := cmd.Process.Pid
+		err := syscall.Kill(-pid, syscall.SIGTERM)
+		time.AfterFunc(killGracePeriod, func() { syscall.Kill(-pid, syscall.SIGKILL) })
+		return err
+	}
+
+	f, ok := cmd.Stdin.(*os.File)
+	if !ok || !foreground {
+		return func() {}
+	}
+	fd := int(f.Fd())
+	fg, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	if err != nil || fg != syscall.Getpgrp() {
+		// Not a terminal, or we're running in the background ourselves
+		return func() {}
+	}
+
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = fd
+	return func() {
+		// We're a background group now; ignore SIGTTOU while taking the terminal back
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, syscall.Getpgrp())
+	}
+}
+
+// signalProcessGroup delivers sig to every process in p's group.
+func signalProcessGroup(p *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+// processAlive reports whether a process with the given pid exists.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// detachProcess starts cmd in a session of its own, so it keeps running when the
+// terminal closes and isn't stopped by Ctrl-C there.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// lockFile blocks until it holds an exclusive lock on f. Closing f releases it.
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// stopProcess asks the process with the given pid to terminate.
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	projectIndexFileName = "projects.json"
+
+	// projectStatusTimeout bounds how long we wait for git in a single project,
+	// so one slow (e.g. network-mounted) repo can't block the picker.
+	projectStatusTimeout = 800 * time.Millisecond
+
+	// projectStatusWorkers limits how many git processes run at once.
+	projectStatusWorkers = 8
+)
+
+// Project is a directory discovered under one of the configured project_dirs.
+type Project struct {
+	Name string   `json:"name"`           // Display name, relative to Root (e.g. "colonsh" or "org/colonsh")
+	Path string   `json:"path"`           // Absolute path to the project
+	Root string   `json:"root,omitempty"` // The expanded ProjectDir path it was found under
+	Slug string   `json:"slug,omitempty"` // "user/repo" from the origin remote, if any
+	Tags []string `json:"tags"`
+
+	Bookmark bool `json:"bookmark,omitempty"` // True for entries from the bookmarks section
+}
+
+// indexedProject is a project registered outside the project_dirs scan, e.g. by :pclone.
+type indexedProject struct {
+	Path    string    `json:"path"`
+	Root    string    `json:"root"`
+	Slug    string    `json:"slug,omitempty"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// projectStatus is a snapshot of a project's git state used to decorate picker rows.
+type projectStatus struct {
+	IsRepo     bool
+	Branch     string
+	Dirty      bool
+	Ahead      int
+	Behind     int
+	LastCommit time.Time
+	TimedOut   bool
+}
+
+// discoverProjects lists the immediate subdirectories of every configured ProjectDir,
+// then projects from the project index, followed by the configured bookmarks.
+// A bookmark of a directory already listed renames that entry and adds its tags.
+func discoverProjects(cfg *Config) ([]Project, error) {
+	var projects []Project
+	seen := make(map[string]int) // Index in projects by path
+	rootTags := make(map[string][]string)
+
+	for _, pd := range cfg.ProjectDirs {
+		root, err := expandTilde(pd.Path)
+		if err != nil {
+			return nil, err
+		}
+		rootTags[root] = pd.Tags
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+
+		exclude := make(map[string]struct{}, len(pd.Exclude))
+		for _, ex := range pd.Exclude {
+			exclude[ex] = struct{}{}
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			name := e.Name()
+			if _, skip := exclude[name]; skip {
+				continue
+			}
+			seen[filepath.Join(root, name)] = len(projects)
+			projects = append(projects, Project{
+				Name: name,
+				Path: filepath.Join(root, name),
+				Root: root,
+				Tags: append([]string{}, pd.Tags...),
+			})
+		}
+	}
+
+	// Projects registered by :pclone/:pnew may live deeper than the first level
+	index, err := loadProjectIndex()
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range index {
+		if _, dup := seen[ip.Path]; dup {
+			continue
+		}
+		if info, err := os.Stat(ip.Path); err != nil || !info.IsDir() {
+			continue
+		}
+		name, err := filepath.Rel(ip.Root, ip.Path)
+		if err != nil || ip.Root == "" {
+			name = filepath.Base(ip.Path)
+		}
+		seen[ip.Path] = len(projects)
+		projects = append(projects, Project{
+			Name: filepath.ToSlash(name),
+			Path: ip.Path,
+			Root: ip.Root,
+			Slug: ip.Slug,
+			Tags: append([]string{}, rootTags[ip.Root]...),
+		})
+	}
+
+	for _, b := range cfg.Bookmarks {
+		if b.Name == "" || b.Path == "" {
+			continue
+		}
+		path, err := expandTilde(b.Path)
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Clean(path)
+		if i, dup := seen[path]; dup {
+			p := &projects[i]
+			p.Name = b.Name
+			p.Tags = mergeTags(p.Tags, b.Tags)
+			p.Bookmark = true
+			continue
+		}
+		seen[path] = len(projects)
+		projects = append(projects, Project{
+			Name:     b.Name,
+			Path:     path,
+			Tags:     append([]string{}, b.Tags...),
+			Bookmark: true,
+		})
+	}
+
+	return projects, nil
+}
+
+// applyRepoTags resolves each project's slug and merges in the tags of its matching GitRepos.
+func applyRepoTags(cfg *Config, projects []Project) {
+	repoTags := func(slug string) []string {
+		var tags []string
+		for _, r := range matchingRepos(cfg, slug) {
+			tags = append(tags, r.Tags...)
+		}
+		return tags
+	}
+
+	forEachConcurrent(len(projects), func(i int) {
+		p := &projects[i]
+		if p.Slug != "" {
+			// Already known from the project index
+			p.Tags = mergeTags(p.Tags, repoTags(p.Slug))
+			return
+		}
+		if _, err := os.Stat(filepath.Join(p.Path, ".git")); err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), projectStatusTimeout)
+		defer cancel()
+		out, err := gitOutputContext(ctx, p.Path, "config", "--get", "remote.origin.url")
+		if err != nil {
+			return
+		}
+		if slug, err := slugFromRemoteURL(out); err == nil {
+			p.Slug = slug
+			p.Tags = mergeTags(p.Tags, repoTags(slug))
+		}
+	})
+}
+
+// filterProjectsByTags keeps the projects that carry every one of the given tags.
+func filterProjectsByTags(projects []Project, tags []string) []Project {
+	if len(tags) == 0 {
+		return projects
+	}
+	var out []Project
+	for _, p := range projects {
+		if hasAllTags(p.Tags, tags) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func hasAllTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if strings.EqualFold(h, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTags appends extra tags to base, skipping duplicates.
+func mergeTags(base, extra []string) []string {
+	for _, t := range extra {
+		if !hasAllTags(base, []string{t}) {
+			base = append(base, t)
+		}
+	}
+	return base
+}
+
+// parseTagArgs extracts "--tag x", "--tag=x" and "@x" filters, returning the remaining args.
+func parseTagArgs(args []string) (tags []string, rest []string, err error) {
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--tag":
+			if i+1 >= len(args) {
+				return nil, nil, errors.New("--tag requires a value")
+			}
+			i++
+			tags = append(tags, args[i])
+		case strings.HasPrefix(a, "--tag="):
+			tags = append(tags, strings.TrimPrefix(a, "--tag="))
+		case strings.HasPrefix(a, "@") && len(a) > 1:
+			tags = append(tags, strings.TrimPrefix(a, "@"))
+		default:
+			rest = append(rest, a)
+		}
+	}
+	return tags, rest, nil
+}
+
+// forEachConcurrent calls fn for every index in [0, n) using a bounded worker pool.
+func forEachConcurrent(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, projectStatusWorkers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// collectProjectStatuses gathers git status for all projects concurrently.
+// The returned slice is index-aligned with projects.
+func collectProjectStatuses(projects []Project) []projectStatus {
+	statuses := make([]projectStatus, len(projects))
+	forEachConcurrent(len(projects), func(i int) {
+		ctx, cancel := context.WithTimeout(context.Background(), projectStatusTimeout)
+		defer cancel()
+		statuses[i] = readProjectStatus(ctx, projects[i].Path)
+	})
+	return statuses
+}
+
+// readProjectStatus inspects a single project. Non-git directories return a zero status.
+func readProjectStatus(ctx context.Context, dir string) projectStatus {
+	var st projectStatus
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return st
+	}
+	st.IsRepo = true
+
+	out, err := gitOutputContext(ctx, dir, "status", "--porcelain=v2", "--branch")
+	if err != nil {
+		st.TimedOut = ctx.Err() != nil
+		return st
+	}
+	for _, line := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(line, "# branch.head "):
+			st.Branch = strings.TrimPrefix(line, "# branch.head ")
+		case strings.HasPrefix(line, "# branch.ab "):
+			// Format: "# branch.ab +<ahead> -<behind>"
+			fields := strings.Fields(strings.TrimPrefix(line, "# branch.ab "))
+			if len(fields) == 2 {
+				st.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+				st.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "-"))
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+			// Other headers and blank lines carry no file state
+		default:
+			st.Dirty = true
+		}
+	}
+
+	// Repos without commits yet report an error here; that's fine.
+	if out, err := gitOutputContext(ctx, dir, "log", "-1", "--format=%ct"); err == nil {
+		if ts, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64); err == nil {
+			st.LastCommit = time.Unix(ts, 0)
+		}
+	} else if ctx.Err() != nil {
+		st.TimedOut = true
+	}
+
+	return st
+}
+
+// gitOutputContext runs a read-only git command in dir and returns its stdout.
+func gitOutputContext(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// projectRowLabel renders a picker row: name, branch, state markers and commit age.
+func projectRowLabel(p Project, st projectStatus, nameWidth, branchWidth int) string {
+	if !st.IsRepo {
+		if p.Bookmark {
+			return padRight(p.Name, nameWidth) + "  (bookmark)"
+		}
+		return p.Name
+	}
+	if st.TimedOut && st.Branch == "" {
+		return padRight(p.Name, nameWidth) + "  (timed out)"
+	}
+
+	var marks []string
+	if st.Dirty {
+		marks = append(marks, "*")
+	}
+	if st.Ahead > 0 {
+		marks = append(marks, fmt.Sprintf("↑%d", st.Ahead))
+	}
+	if st.Behind > 0 {
+		marks = append(marks, fmt.Sprintf("↓%d", st.Behind))
+	}
+
+	age := ""
+	if !st.LastCommit.IsZero() {
+		age = humanAge(time.Since(st.LastCommit))
+	}
+
+	columns := []string{padRight(p.Name, nameWidth), padRight(st.Branch, branchWidth), padRight(strings.Join(marks, " "), 8), age}
+	return strings.TrimRight(strings.Join(columns, "  "), " ")
+}
+
+// padRight pads s with spaces to width terminal columns. Unlike %-*s it measures
+// display width, so wide characters in names and branches keep the columns aligned.
+func padRight(s string, width int) string {
+	return s + strings.Repeat(" ", max(width-lipgloss.Width(s), 0))
+}
+
+// humanAge formats a duration in the compact style used by the picker (e.g. "5m", "3d", "2y").
+func humanAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(d.Hours()/(24*30)))
+	default:
+		return fmt.Sprintf("%dy", int(d.Hours()/(24*365)))
+	}
+}
+
+// projectIndexPath returns the location of the project index in the state dir.
+func projectIndexPath() (string, error) {
+	dir, err := colonStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, projectIndexFileName), nil
+}
+
+// loadProjectIndex reads the project index. A missing index is not an error.
+func loadProjectIndex() ([]indexedProject, error) {
+	path, err := projectIndexPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var index []indexedProject
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return index, nil
+}
+
+// registerProject adds (or refreshes) a project in the index so :pd can find it.
+func registerProject(p indexedProject) error {
+	index, err := loadProjectIndex()
+	if err != nil {
+		return err
+	}
+
+	kept := index[:0]
+	for _, ip := range index {
+		if ip.Path != p.Path {
+			kept = append(kept, ip)
+		}
+	}
+	if p.AddedAt.IsZero() {
+		p.AddedAt = time.Now()
+	}
+	index = append(kept, p)
+
+	path, err := projectIndexPath()
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		printDryRunWrite("project index", path)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(index, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestProjectRowLabelAligns(t *testing.T) {
+	commit := time.Now().Add(-3 * time.Hour)
+	rows := []struct {
+		p  Project
+		st projectStatus
+	}{
+		{Project{Name: "api"}, projectStatus{IsRepo: true, Branch: "main", LastCommit: commit}},
+		{Project{Name: "web"}, projectStatus{IsRepo: true, Branch: "main", Dirty: true, LastCommit: commit}},
+		{Project{Name: "cli"}, projectStatus{IsRepo: true, Branch: "feat/↑", Ahead: 2, Behind: 11, LastCommit: commit}},
+		{Project{Name: "文档"}, projectStatus{IsRepo: true, Branch: "修复", Dirty: true, Ahead: 1, LastCommit: commit}},
+	}
+	nameWidth, branchWidth := 0, 0
+	for _, r := range rows {
+		nameWidth = max(nameWidth, lipgloss.Width(r.p.Name))
+		branchWidth = max(branchWidth, lipgloss.Width(r.st.Branch))
+	}
+
+	// The age column starts at the same terminal column on every row
+	want := -1
+	for _, r := range rows {
+		label := projectRowLabel(r.p, r.st, nameWidth, branchWidth)
+		col := lipgloss.Width(label[:strings.LastIndex(label, " ")+1])
+		if want < 0 {
+			want = col
+		} else if col != want {
+			t.Errorf("age of %q starts at column %d, want %d", label, col, want)
+		}
+	}
+}
+
+func TestDiscoverProjectsMergesBookmarks(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+	root := filepath.Join(tmp, "code")
+	for _, dir := range []string{"api", "web"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &Config{
+		ProjectDirs: []ProjectDir{{Path: root, Tags: []string{"work"}}},
+		Bookmarks: []Bookmark{
+			{Name: "API", Path: filepath.Join(root, "api") + string(filepath.Separator), Tags: []string{"backend", "work"}},
+			{Name: "notes", Path: filepath.Join(tmp, "notes")},
+		},
+	}
+	projects, err := discoverProjects(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type entry struct {
+		Name     string
+		Tags     []string
+		Bookmark bool
+	}
+	var got []entry
+	for _, p := range projects {
+		got = append(got, entry{p.Name, p.Tags, p.Bookmark})
+	}
+	want := []entry{
+		{"API", []string{"work", "backend"}, true},
+		{"web", []string{"work"}, false},
+		{"notes", []string{}, true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("discoverProjects = %+v, want %+v", got, want)
+	}
+}
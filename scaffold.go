@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+)
+
+func cmdProjectNew(cfg *Config, args []string) error {
+	var positional []string
+	dirFlag := ""
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == "--dir":
+			if i+1 >= len(args) {
+				return errors.New("--dir requires a value")
+			}
+			i++
+			dirFlag = args[i]
+		case strings.HasPrefix(a, "--dir="):
+			dirFlag = strings.TrimPrefix(a, "--dir=")
+		default:
+			positional = append(positional, a)
+		}
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: colonsh pnew <template> <name> [--dir path]")
+	}
+	tmplName, name := positional[0], positional[1]
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid project name %q", name)
+	}
+
+	tmpl := findTemplate(cfg, tmplName)
+	if tmpl == nil {
+		var names []string
+		for _, t := range cfg.Templates {
+			names = append(names, t.Name)
+		}
+		return fmt.Errorf("no template named %q (available: %s)", tmplName, strings.Join(names, ", "))
+	}
+
+	root, err := chooseProjectDir(cfg, dirFlag)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(root, name)
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("destination %s already exists", dest)
+	}
+
+	// Git-hosted templates are fetched into a temp dir first, then copied like local ones.
+	src, cleanup, err := fetchTemplateSource(tmpl.Source)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	module := tmpl.Module
+	if module == "" {
+		module = name
+	}
+	replacer := strings.NewReplacer(
+		"{{name}}", name,
+		"{{module}}", strings.ReplaceAll(module, "{{name}}", name),
+	)
+
+	fmt.Fprintf(os.Stderr, "Creating %s from template %q\n", dest, tmpl.Name)
+	if dryRun {
+		printDryRunWrite("template files", dest)
+	} else if err := copyTemplate(src, dest, replacer); err != nil {
+		// Don't leave a half-copied project behind to block the next attempt
+		os.RemoveAll(dest)
+		return err
+	}
+
+	initCmd := exec.Command("git", "init")
+	initCmd.Dir = dest
+	initCmd.Stdout = os.Stderr
+	initCmd.Stderr = os.Stderr
+	if err := runCmd(initCmd); err != nil {
+		return fmt.Errorf("git init failed: %w", err)
+	}
+
+	if err := registerProject(indexedProject{Path: dest, Root: root}); err != nil {
+		return fmt.Errorf("created %s, but failed to update project index: %w", dest, err)
+	}
+
+	for _, hook := range tmpl.PostCreate {
+		hook = replacer.Replace(hook)
+		fmt.Fprintf(os.Stderr, "Running post-create: %s\n", hook)
+		hookCmd := shellCommand{Cmd: hook, Dir: dest, Stdout: os.Stderr}
+		if err := hookCmd.Run(context.Background()); err != nil {
+			return fmt.Errorf("created %s, but post-create %q failed: %w", dest, hook, err)
+		}
+	}
+
+	fmt.Println(dest)
+	return nil
+}
+
+// findTemplate looks up a template by name, ignoring case.
+func findTemplate(cfg *Config, name string) *ProjectTemplate {
+	for i := range cfg.Templates {
+		if strings.EqualFold(cfg.Templates[i].Name, name) {
+			return &cfg.Templates[i]
+		}
+	}
+	return nil
+}
+
+// chooseProjectDir resolves --dir, or asks which ProjectDir to use when there is more than one.
+func chooseProjectDir(cfg *Config, want string) (string, error) {
+	if want != "" {
+		_, root, err := matchProjectDir(cfg, want)
+		return root, err
+	}
+	switch len(cfg.ProjectDirs) {
+	case 0:
+		return "", errors.New("no project_dirs configured")
+	case 1:
+		return expandTilde(cfg.ProjectDirs[0].Path)
+	}
+
+	opts := []huh.Option[string]{}
+	for _, pd := range cfg.ProjectDirs {
+		root, err := expandTilde(pd.Path)
+		if err != nil {
+			return "", err
+		}
+		opts = append(opts, huh.NewOption(pd.Path, root))
+	}
+
+	var selected string
+	if err := huh.NewSelect[string]().
+		Title("Create project in").
+		Options(opts...).
+		Value(&selected).
+		Run(); err != nil {
+		return "", err
+	}
+	if selected == "" {
+		return "", errors.New("no project directory selected")
+	}
+	return selected, nil
+}
+
+// fetchTemplateSource returns a local directory for the template source, cloning git URLs
+// into a temporary directory. The returned cleanup func removes anything it created.
+func fetchTemplateSource(source string) (string, func(), error) {
+	noop := func() {}
+	if source == "" {
+		return "", noop, errors.New("template has no source")
+	}
+
+	if !isGitURL(source) {
+		dir, err := expandTilde(source)
+		if err != nil {
+			return "", noop, err
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return "", noop, fmt.Errorf("template source %s is not a directory", dir)
+		}
+		return dir, noop, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "colonsh-template-")
+	if err != nil {
+		return "", noop, err
+	}
+	cleanup := func() { os.RemoveAll(tmp) }
+
+	cmd := exec.Command("git", "clone", "--depth", "1", source, tmp)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := runCmd(cmd); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to clone template %s: %w", source, err)
+	}
+	return tmp, cleanup, nil
+}
+
+// isGitURL reports whether s looks like a remote git URL rather than a local path.
+func isGitURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(s, "git@") || strings.HasSuffix(s, ".git")
+}
+
+// copyTemplate copies src into dest, skipping .git and applying the replacer to
+// relative paths and to the contents of text files.
+func copyTemplate(src, dest string, r *strings.Replacer) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		target := filepath.Join(dest, r.Replace(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0o700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(r.Replace(link), target)
+		default:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			// Leave binary files untouched
+			if !bytes.ContainsRune(data, 0) {
+				data = []byte(r.Replace(string(data)))
+			}
+			return os.WriteFile(target, data, info.Mode().Perm())
+		}
+	})
+}
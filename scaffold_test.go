@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestProjectNew(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+
+	tmpl := filepath.Join(tmp, "tmpl")
+	for name, content := range map[string]string{
+		"go.mod":               "module {{module}}\n",
+		"cmd/{{name}}/main.go": "package main // {{name}}\n",
+	} {
+		path := filepath.Join(tmpl, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(tmp, "code")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		ProjectDirs: []ProjectDir{{Path: root}},
+		Templates:   []ProjectTemplate{{Name: "go", Source: tmpl, Module: "example.com/{{name}}"}},
+	}
+
+	if err := cmdProjectNew(cfg, []string{"go", "demo"}); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(root, "demo")
+	for name, want := range map[string]string{
+		"go.mod":           "module example.com/demo\n",
+		"cmd/demo/main.go": "package main // demo\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, ".git")); err != nil {
+		t.Errorf("project was not initialized as a git repository: %v", err)
+	}
+	if err := cmdProjectNew(cfg, []string{"go", "demo"}); err == nil {
+		t.Error("creating a project over an existing one succeeded")
+	}
+
+	// "{{name}}" becomes the path of the directory copied before it, so the copy fails
+	if err := os.WriteFile(filepath.Join(tmpl, "cmd", "{{name}}.txt"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(tmpl, "cmd", "broken.txt"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdProjectNew(cfg, []string{"go", "broken"}); err == nil {
+		t.Fatal("cmdProjectNew with a conflicting template succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(root, "broken")); !os.IsNotExist(err) {
+		t.Errorf("a failed copy left %s behind: %v", filepath.Join(root, "broken"), err)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long file events must settle before an action is rerun.
+const watchDebounce = 300 * time.Millisecond
+
+// watch runs plan, then reruns it whenever files matching the target's watch globs
+// change under the workspace root. A change during a run kills that run first.
+// Runs don't get the terminal's foreground, so Ctrl-C stops the watch and the run.
+func (r *actionRun) watch(ctx context.Context, lc *LogConfig, plan, steps []*RepoAction) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	root := r.ws.Root
+	if err := addWatchDirs(watcher, root, root); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	globs := r.target.Watch
+	var cancelRun context.CancelFunc
+	runDone := make(chan struct{})
+	close(runDone)
+
+	start := func(reason string) {
+		if cancelRun != nil {
+			cancelRun()
+		}
+		<-runDone
+		clearScreen()
+		if reason != "" {
+			fmt.Printf("Change detected: %s\n", reason)
+		}
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		cancelRun, runDone = cancel, done
+		go func() {
+			defer close(done)
+			// A failing pre_action hook skips this run, not the watch
+			err := r.runHook(hookPreAction, steps)
+			if err == nil {
+				r.startLog(lc, steps)
+				started := time.Now()
+				err = r.runPlan(runCtx, plan)
+				r.finish(steps, started, err)
+			}
+			if runCtx.Err() != nil {
+				return
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "colonsh:", err)
+			}
+			fmt.Printf("\nWatching %s for changes (Ctrl-C to stop)...\n", root)
+		}()
+	}
+
+	start("")
+
+	pending := make(map[string]bool)
+	debounce := time.NewTimer(0)
+	<-debounce.C
+	for {
+		select {
+		case <-ctx.Done():
+			if cancelRun != nil {
+				cancelRun()
+			}
+			<-runDone
+			return nil
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "colonsh: watch error:", err)
+
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			rel, err := filepath.Rel(root, ev.Name)
+			if err != nil || ev.Op == fsnotify.Chmod || slices.Contains(strings.Split(filepath.ToSlash(rel), "/"), ".git") {
+				continue
+			}
+			if ev.Has(fsnotify.Create) {
+				// New directories need their own watches
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					if err := addWatchDirs(watcher, root, ev.Name); err != nil {
+						fmt.Fprintln(os.Stderr, "colonsh: watch error:", err)
+					}
+				}
+			}
+			pending[filepath.ToSlash(rel)] = true
+			debounce.Reset(watchDebounce)
+
+		case <-debounce.C:
+			changed := changedWatchPaths(root, pending, globs)
+			clear(pending)
+			if len(changed) > 0 {
+				reason := changed[0]
+				if len(changed) > 1 {
+					reason += fmt.Sprintf(" and %d more", len(changed)-1)
+				}
+				start(reason)
+			}
+		}
+	}
+}
+
+// changedWatchPaths returns the pending paths that match globs and aren't ignored by git.
+func changedWatchPaths(root string, pending map[string]bool, globs []string) []string {
+	var candidates []string
+	for rel := range pending {
+		if len(globs) == 0 || slices.ContainsFunc(globs, func(g string) bool { return matchWatchGlob(g, rel) }) {
+			candidates = append(candidates, rel)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	ignored := gitIgnored(root, candidates)
+	var changed []string
+	for _, rel := range candidates {
+		if !ignored[rel] {
+			changed = append(changed, rel)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// addWatchDirs watches dir and every directory below it, skipping .git and
+// directories ignored by git. fsnotify watches aren't recursive.
+func addWatchDirs(w *fsnotify.Watcher, root, dir string) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories may vanish while we walk; skip what we can't read
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, p)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Ask git once for the whole batch, then drop ignored directories and their children
+	rels := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		rel, _ := filepath.Rel(root, d)
+		rels = append(rels, filepath.ToSlash(rel)+"/")
+	}
+	ignored := gitIgnored(root, rels)
+	var skip []string
+	for i, d := range dirs {
+		if ignored[rels[i]] || slices.ContainsFunc(skip, func(s string) bool { return isWithin(d, s) }) {
+			skip = append(skip, d)
+			continue
+		}
+		if err := w.Add(d); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", d, err)
+		}
+	}
+	return nil
+}
+
+// gitIgnored returns which of the given root-relative paths git ignores. Outside a
+// git repository nothing is ignored.
+func gitIgnored(root string, rels []string) map[string]bool {
+	ignored := make(map[string]bool)
+	if len(rels) == 0 {
+		return ignored
+	}
+	cmd := exec.Command("git", "check-ignore", "--stdin", "-z")
+	cmd.Dir = root
+	cmd.Stdin = strings.NewReader(strings.Join(rels, "\x00") + "\x00")
+	out, err := cmd.Output()
+	var ee *exec.ExitError
+	if err != nil && !(errors.As(err, &ee) && ee.ExitCode() == 1) {
+		return ignored
+	}
+	for _, p := range bytes.Split(out, []byte{0}) {
+		if len(p) > 0 {
+			ignored[string(p)] = true
+		}
+	}
+	return ignored
+}
+
+// matchWatchGlob reports whether rel, a slash-separated path relative to the root,
+// matches pattern. Patterns without a slash match the file name at any depth;
+// "**" matches any number of directories.
+func matchWatchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchGlobSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchGlobSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// clearScreen clears the terminal between watch runs. Redirected output is left alone.
+func clearScreen() {
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print("\x1b[H\x1b[2J\x1b[3J")
+	}
+}
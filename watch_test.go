@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatchWatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		// No slash: the file name at any depth
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "main.go.orig", false},
+		{"Makefile", "sub/Makefile", true},
+		// With a slash: the whole path, one segment per segment
+		{"src/*.ts", "src/app.ts", true},
+		{"src/*.ts", "src/lib/app.ts", false},
+		{"src/*.ts", "other/src/app.ts", false},
+		// ** matches zero or more directories
+		{"src/**/*.ts", "src/app.ts", true},
+		{"src/**/*.ts", "src/a/b/c/app.ts", true},
+		{"src/**/*.ts", "lib/app.ts", false},
+		{"**/testdata/*", "testdata/x.json", true},
+		{"**/testdata/*", "pkg/a/testdata/x.json", true},
+		{"**/testdata/*", "pkg/testdata/sub/x.json", false},
+		{"docs/**", "docs", true},
+		{"docs/**", "docs/guide/intro.md", true},
+		{"a/**/b/**/c", "a/x/b/y/z/c", true},
+		{"a/**/b/**/c", "a/x/y/c", false},
+		// Character classes and escapes come from path.Match
+		{"v[0-9]/*.json", "v2/schema.json", true},
+		{"v[0-9]/*.json", "vx/schema.json", false},
+		{"bad[/*.go", "bad[/main.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchWatchGlob(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchWatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestWatchStopsWhenCancelled(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(tmp, "state"))
+	t.Setenv("SHELL", "/bin/sh")
+	root := filepath.Join(tmp, "repo")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := []RepoAction{{Name: "serve", Cmd: "touch started; sleep 30"}}
+	run := &actionRun{ws: &workspace{Root: root}, actions: actions, target: &actions[0], args: actionArgs{Watch: true}}
+	steps := []*RepoAction{&actions[0]}
+
+	sc, _, err := run.command(&actions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.NoForeground {
+		t.Error("watch runs take the terminal's foreground, so Ctrl-C can't stop the watch")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run.watch(ctx, nil, steps, steps) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(filepath.Join(root, "started")); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the watched action never started")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watch = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch kept running after it was cancelled")
+	}
+}
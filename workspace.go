@@ -0,0 +1,250 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// workspace is the directory that :po and :pa operate on. It is either a git
+// repository (optionally configured in git_repos), a bookmarked directory, or both.
+type workspace struct {
+	Root     string
+	Slug     string     // "user/repo" of the git repository, if it has an origin remote
+	Repo     *GitRepo   // The git_repos entry whose slug matches exactly
+	Repos    []*GitRepo // Every matching git_repos entry, exact matches first
+	Bookmark *Bookmark
+	Global   []RepoAction
+	Dir      string // Current directory relative to Root, slash-separated; "." at the root
+}
+
+// actionSection is a run of actions from one source, shown under a header in the :pa menu.
+type actionSection struct {
+	Title string
+	Start int // Index of the section's first action
+}
+
+// currentWorkspace resolves the workspace for the current directory. When the
+// directory is inside both a git repository and a bookmark, the deeper of the
+// two roots wins, and a bookmark sharing the git root contributes alongside it.
+func currentWorkspace(cfg *Config) (*workspace, error) {
+	ws := workspace{Global: cfg.GlobalActions}
+
+	if inGitRepo() {
+		root, err := gitRoot()
+		if err != nil {
+			return nil, err
+		}
+		ws.Root = root
+		ws.Slug, _ = gitRepoSlug()
+		ws.Repos = matchingRepos(cfg, ws.Slug)
+		if len(ws.Repos) > 0 && ws.Repos[0].Slug == ws.Slug {
+			ws.Repo = ws.Repos[0]
+		}
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	if b, broot := findBookmarkFor(cfg, cwd); b != nil {
+		switch {
+		case ws.Root == "":
+			ws.Root = broot
+			ws.Bookmark = b
+		case broot == resolvePath(ws.Root):
+			ws.Bookmark = b
+		case isWithin(broot, resolvePath(ws.Root)):
+			// Bookmark nested inside the repository is the more specific match
+			ws.Root = broot
+			ws.Repo, ws.Repos = nil, nil
+			ws.Bookmark = b
+		}
+	}
+
+	if ws.Root == "" {
+		return nil, errors.New("not inside a git repository or bookmarked directory")
+	}
+	ws.Dir = "."
+	if rel, err := filepath.Rel(resolvePath(ws.Root), resolvePath(cwd)); err == nil && filepath.IsLocal(rel) {
+		ws.Dir = filepath.ToSlash(rel)
+	}
+	return &ws, nil
+}
+
+// actions returns the actions available in this workspace, grouped into sections by
+// source: the bookmark, each matching git_repos entry, global_actions, then actions
+// generated from task files in the root. When configured sources define an action
+// with the same name, the most specific one wins; generated actions never override
+// configured ones, and clashes between generated sources are qualified with the tool.
+func (w *workspace) actions() ([]RepoAction, []actionSection) {
+	var actions []RepoAction
+	var sections []actionSection
+	seen := make(map[string]bool)
+	configured := make(map[string]bool)
+	add := func(title, tool string, list []RepoAction) {
+		start := len(actions)
+		for _, a := range list {
+			key := strings.ToLower(a.Name)
+			if tool != "" && seen[key] && !configured[key] {
+				a.Name = tool + " " + a.Name
+				key = strings.ToLower(a.Name)
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if tool == "" {
+				configured[key] = true
+			}
+			actions = append(actions, a)
+		}
+		if len(actions) > start {
+			sections = append(sections, actionSection{Title: title, Start: start})
+		}
+	}
+
+	if w.Bookmark != nil {
+		add(w.Bookmark.Name, "", w.Bookmark.Actions)
+	}
+	for _, r := range w.Repos {
+		add(cmp.Or(r.Name, r.Slug), "", r.Actions)
+	}
+	add("global", "", w.Global)
+	if w.discover() {
+		for _, src := range discoverActions(w.Root) {
+			add(src.Title, src.Tool, src.Actions)
+		}
+	}
+	return actions, sections
+}
+
+// discover reports whether actions should be generated from task files: unless the
+// bookmark or any matching git_repos entry sets "discover": false.
+func (w *workspace) discover() bool {
+	if w.Bookmark != nil && w.Bookmark.Discover != nil && !*w.Bookmark.Discover {
+		return false
+	}
+	for _, r := range w.Repos {
+		if r.Discover != nil && !*r.Discover {
+			return false
+		}
+	}
+	return true
+}
+
+// name is what a user types to confirm high-danger actions: the bookmark name,
+// the configured repo name, the repo part of the slug, or the root directory's name.
+func (w *workspace) name() string {
+	switch {
+	case w.Bookmark != nil:
+		return w.Bookmark.Name
+	case w.Repo != nil && w.Repo.Name != "":
+		return w.Repo.Name
+	case w.Slug != "":
+		return w.Slug[strings.LastIndex(w.Slug, "/")+1:]
+	default:
+		return filepath.Base(w.Root)
+	}
+}
+
+// openCmd picks the command used by :po, preferring bookmark, then matching repos, then global settings.
+func (w *workspace) openCmd(cfg *Config) string {
+	if w.Bookmark != nil && w.Bookmark.OpenCmd != "" {
+		return w.Bookmark.OpenCmd
+	}
+	for _, r := range w.Repos {
+		if r.OpenCmd != "" {
+			return r.OpenCmd
+		}
+	}
+	if cfg.OpenCmd != "" {
+		return cfg.OpenCmd
+	}
+	return "code ."
+}
+
+// matchingRepos returns the git_repos entries matching slug: exact matches first,
+// then glob ("myorg/*") and regular expression ("re:^infra-") patterns in config order.
+func matchingRepos(cfg *Config, slug string) []*GitRepo {
+	if slug == "" {
+		return nil
+	}
+	var exact, patterns []*GitRepo
+	for i := range cfg.GitRepos {
+		r := &cfg.GitRepos[i]
+		switch {
+		case r.Slug == slug:
+			exact = append(exact, r)
+		case slugPatternMatches(r.Slug, slug):
+			patterns = append(patterns, r)
+		}
+	}
+	return append(exact, patterns...)
+}
+
+// slugPatternMatches reports whether a git_repos slug pattern matches slug. Patterns
+// starting with "re:" are regular expressions; anything else is a path.Match glob.
+// Both were validated when the config was loaded.
+func slugPatternMatches(pattern, slug string) bool {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		return err == nil && re.MatchString(slug)
+	}
+	ok, _ := path.Match(pattern, slug)
+	return ok
+}
+
+// findBookmarkFor returns the most specific bookmark containing dir, along with its resolved path.
+func findBookmarkFor(cfg *Config, dir string) (*Bookmark, string) {
+	dir = resolvePath(dir)
+
+	var best *Bookmark
+	bestRoot := ""
+	for i := range cfg.Bookmarks {
+		p, err := expandTilde(cfg.Bookmarks[i].Path)
+		if err != nil || p == "" {
+			continue
+		}
+		p = resolvePath(p)
+		if isWithin(dir, p) && len(p) > len(bestRoot) {
+			best = &cfg.Bookmarks[i]
+			bestRoot = p
+		}
+	}
+	return best, bestRoot
+}
+
+// findBookmarkByName looks up a bookmark by its name, ignoring case.
+func findBookmarkByName(cfg *Config, name string) *Bookmark {
+	for i := range cfg.Bookmarks {
+		if strings.EqualFold(cfg.Bookmarks[i].Name, name) {
+			return &cfg.Bookmarks[i]
+		}
+	}
+	return nil
+}
+
+// resolvePath returns an absolute, symlink-free version of p where possible.
+func resolvePath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return p
+}
+
+// isWithin reports whether path is root itself or nested below it.
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
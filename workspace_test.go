@@ -0,0 +1,54 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSlugPatternMatches(t *testing.T) {
+	tests := []struct {
+		pattern, slug string
+		want          bool
+	}{
+		{"acme/api", "acme/api", true},
+		{"acme/*", "acme/api", true},
+		{"acme/*", "acme/group/api", false}, // * doesn't cross a slash
+		{"acme/*", "other/api", false},
+		{"*/infra-*", "acme/infra-dns", true},
+		{"acme/api-?", "acme/api-2", true},
+		{"acme/[ab]pi", "acme/bpi", true},
+		{"re:^acme/infra-", "acme/infra-dns", true},
+		{"re:^acme/infra-", "other/acme/infra-dns", false},
+		{"re:infra", "acme/my-infra-repo", true}, // unanchored
+		{"re:^(acme|corp)/.*-svc$", "corp/billing-svc", true},
+		{"re:^(acme|corp)/.*-svc$", "corp/billing-svc-old", false},
+		{"re:(", "acme/api", false}, // invalid patterns never match
+		{"acme/[", "acme/[", false},
+	}
+	for _, tt := range tests {
+		if got := slugPatternMatches(tt.pattern, tt.slug); got != tt.want {
+			t.Errorf("slugPatternMatches(%q, %q) = %v, want %v", tt.pattern, tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestMatchingRepos(t *testing.T) {
+	cfg := &Config{GitRepos: []GitRepo{
+		{Slug: "acme/*"},
+		{Slug: "re:^acme/"},
+		{Slug: "acme/api"},
+		{Slug: "corp/*"},
+	}}
+	var got []string
+	for _, r := range matchingRepos(cfg, "acme/api") {
+		got = append(got, r.Slug)
+	}
+	// The exact match comes first, then patterns in config order
+	want := []string{"acme/api", "acme/*", "re:^acme/"}
+	if !slices.Equal(got, want) {
+		t.Errorf("matchingRepos = %q, want %q", got, want)
+	}
+	if repos := matchingRepos(cfg, ""); repos != nil {
+		t.Errorf("matchingRepos with no slug = %v, want none", repos)
+	}
+}